@@ -0,0 +1,170 @@
+package gos
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+// references returns the words which contain the CLOCK reference bits of
+// the objects. It must only be called on slabs with the slabFlagClock flag
+func (s *slab) references() []uint64 {
+	offset := metadataLenFor(s.objCount(), s.flags&^slabFlagClock)
+	return unsafe.Slice((*uint64)(unsafe.Pointer(s.addr()+offset)), bitmapWordsFor(s.objCount()))
+}
+
+// touch sets the reference bit of the object at the given index
+// Searches may run concurrently, so the bit gets set atomically
+func (s *slab) touch(idx uint) {
+	atomic.OrUint64(&s.references()[idx/64], 1<<(idx%64))
+}
+
+// touch marks the object at the given address as recently used, unless
+// the pool is frozen and its slabs are read-only
+func (s *slabPool) touch(sl *slab, obj ObjAddr) {
+	if sl.flags&slabFlagClock != 0 && !s.frozen {
+		sl.touch(sl.getObjIdx(obj))
+	}
+}
+
+// clockVictim advances the clock hand of this pool over the object slots
+// and returns the address of the first object which hasn't been used since
+// the hand has passed it the last time. The reference bits of the objects
+// which it passes get cleared, unless the pool is frozen, then the
+// reference bits get ignored
+// The second returned value is false if the pool doesn't contain objects
+func (s *slabPool) clockVictim() (ObjAddr, bool) {
+	if s.objects == 0 {
+		return 0, false
+	}
+
+	// after passing every slot once all reference bits are cleared, so
+	// the second round finds an object
+	for steps := 2*s.capacity + uint64(len(s.slabs)); steps > 0; steps-- {
+		if s.clockSlab >= len(s.slabs) {
+			s.clockSlab, s.clockIdx = 0, 0
+		}
+		sl := s.slabs[s.clockSlab]
+		if s.clockIdx >= sl.objCount() {
+			s.clockSlab, s.clockIdx = s.clockSlab+1, 0
+			continue
+		}
+
+		idx := s.clockIdx
+		s.clockIdx++
+		if !sl.isUsed(idx) {
+			continue
+		}
+		if sl.flags&slabFlagClock != 0 && !s.frozen {
+			references := sl.references()
+			if references[idx/64]&(1<<(idx%64)) != 0 {
+				references[idx/64] &^= 1 << (idx % 64)
+				continue
+			}
+		}
+		return sl.addr() + sl.getObjOffset(idx), true
+	}
+
+	return 0, false
+}
+
+// hasFreeSlot returns true if an object can be added to this pool without
+// adding a slab
+func (s *slabPool) hasFreeSlot() bool {
+	idx, found := s.freeSlabs.NextClear(0)
+	return found && idx < uint(len(s.slabs))
+}
+
+// nextSlabLength returns the number of bytes which get mmapped for the slab
+// which this pool adds once all of its slabs are full
+func (s *slabPool) nextSlabLength(baseObjsPerSlab uint8, growthFactor float64) uint64 {
+	objCount := s.nextObjCount(baseObjsPerSlab, growthFactor)
+	flags := s.flagsForSlab(objCount)
+	return uint64(mappedLenFor(slabLenFor(s.objSize, objCount, flags), flags))
+}
+
+// mappedBytes returns the number of bytes which are mmapped for the slabs
+// of all pools, including the slabs in the quarantine of the debug mode
+func (o *ObjectStore) mappedBytes() uint64 {
+	var total uint64
+	for _, p := range o.slabPools {
+		total += p.mappedRegions
+	}
+	if o.quarantine != nil {
+		total += o.quarantine.mappedBytes()
+	}
+	return total
+}
+
+// makeRoom makes sure that an object can be added to the pool with the
+// given object size without exceeding MaxBytes. In the cache mode it
+// evicts objects until that is the case, objects of the same pool get
+// evicted first. Otherwise it returns an error if the limit would be
+// exceeded
+func (o *ObjectStore) makeRoom(size uint8) error {
+	for {
+		pool, ok := o.slabPools[size]
+		if ok && pool.hasFreeSlot() {
+			return nil
+		}
+
+		var next uint64
+		if ok {
+			next = pool.nextSlabLength(o.config.BaseObjectsPerSlab, o.config.GrowthFactor)
+		} else {
+			next = o.newSlabPool(size).nextSlabLength(o.config.BaseObjectsPerSlab, o.config.GrowthFactor)
+		}
+		if o.mappedBytes()+next <= o.config.MaxBytes {
+			return nil
+		}
+		if !o.config.Cache {
+			return fmt.Errorf("ObjectStore: Add failed because the memory limit of %d bytes has been reached", o.config.MaxBytes)
+		}
+
+		// evicting an object of the same pool frees a slot for the added
+		// object, evicting objects of other pools only frees memory once
+		// one of their slabs is empty, so we pick the largest pool
+		victims := pool
+		if !ok || pool.objects == 0 {
+			victims = nil
+			for _, p := range o.slabPools {
+				if p.objects > 0 && (victims == nil || p.mapped > victims.mapped) {
+					victims = p
+				}
+			}
+		}
+		if victims == nil {
+			return fmt.Errorf("ObjectStore: Add failed because a slab of %d bytes exceeds the memory limit of %d bytes", next, o.config.MaxBytes)
+		}
+
+		obj, found := victims.clockVictim()
+		if found && obj == o.pinned {
+			// the hand has moved past the pinned object, so the next
+			// victim is another object if there is one
+			obj, found = victims.clockVictim()
+			found = found && obj != o.pinned
+		}
+		if !found {
+			return fmt.Errorf("ObjectStore: Add failed to find an object to evict in pool %d", victims.objSize)
+		}
+		if err := o.evict(obj); err != nil {
+			return err
+		}
+	}
+}
+
+// evict deletes the given object after passing it to the OnEvict callback
+func (o *ObjectStore) evict(obj ObjAddr) error {
+	if o.config.OnEvict != nil {
+		sAddr, err := o.getSlabAddress(obj)
+		if err != nil {
+			return err
+		}
+		o.config.OnEvict(obj, slabFromSlabAddr(sAddr).objAt(obj))
+	}
+	if err := o.Delete(obj); err != nil {
+		return err
+	}
+	o.stats.evict()
+	return nil
+}
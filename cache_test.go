@@ -0,0 +1,174 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// slabLen100x4 is the number of bytes which get mmapped for a slab with 100
+// objects of 4 bytes
+var slabLen100x4 = uint64(roundUpToPage(metadataLenFor(100, slabFlagClock) + 400))
+
+func newCacheTestStore(cache bool, evicted map[string]ObjAddr) ObjectStore {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 100
+	c.GrowthFactor = 1
+	c.CollectStats = true
+	c.MaxBytes = 3 * slabLen100x4
+	c.Cache = cache
+	c.OnEvict = func(obj ObjAddr, value []byte) {
+		evicted[string(value)] = obj
+	}
+	return NewObjectStore(c)
+}
+
+func TestMemoryLimit(t *testing.T) {
+	Convey("When adding objects to a store with a memory limit", t, func() {
+		o := newCacheTestStore(false, nil)
+		for i := 0; i < 300; i++ {
+			_, err := o.Add([]byte(fmt.Sprintf("%04d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("Add should fail once the limit has been reached", func() {
+			_, err := o.Add([]byte("full"))
+			So(err, ShouldNotBeNil)
+			_, err = o.Add([]byte("new pool"))
+			So(err, ShouldNotBeNil)
+			So(o.Len(), ShouldEqual, 300)
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+}
+
+func TestMemoryLimitWithDebug(t *testing.T) {
+	Convey("When adding objects to a debug store with a memory limit", t, func() {
+		c := NewConfig()
+		c.BaseObjectsPerSlab = 100
+		c.GrowthFactor = 1
+		c.Debug = true
+		c.MaxBytes = 3 * slabLen100x4
+		o := NewObjectStore(c)
+		addrs := make([]ObjAddr, 100)
+		for i := range addrs {
+			var err error
+			addrs[i], err = o.Add([]byte(fmt.Sprintf("%04d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the guard pages should count towards the limit", func() {
+			So(o.mappedBytes(), ShouldEqual, slabLen100x4+2*uint64(pageSize))
+			_, err := o.Add([]byte("full"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("quarantined slabs should count towards the limit", func() {
+			for _, addr := range addrs {
+				So(o.Delete(addr), ShouldBeNil)
+			}
+			So(o.mappedBytes(), ShouldEqual, slabLen100x4+2*uint64(pageSize))
+			_, err := o.Add([]byte("full"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestCache(t *testing.T) {
+	Convey("Cache can't be used in debug mode", t, func() {
+		_, err := New(WithDebug(0), WithMaxBytes(1<<20), WithCache(nil))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("When adding more objects to a cache than fit into its memory limit", t, func() {
+		evicted := make(map[string]ObjAddr)
+		o := newCacheTestStore(true, evicted)
+		addrs := make([]ObjAddr, 300)
+		for i := range addrs {
+			var err error
+			addrs[i], err = o.Add([]byte(fmt.Sprintf("%04d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		// the first 100 objects are hot
+		for _, addr := range addrs[:100] {
+			_, err := o.Get(addr)
+			So(err, ShouldBeNil)
+		}
+		for i := 300; i < 400; i++ {
+			_, err := o.Add([]byte(fmt.Sprintf("%04d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("cold objects should have been evicted", func() {
+			So(o.Len(), ShouldEqual, 300)
+			So(evicted, ShouldHaveLength, 100)
+			So(o.Stats().Evictions, ShouldEqual, 100)
+			So(o.mappedBytes(), ShouldBeLessThanOrEqualTo, 3*slabLen100x4)
+			for i := 0; i < 100; i++ {
+				_, found := o.Search([]byte(fmt.Sprintf("%04d", i)))
+				So(found, ShouldBeTrue)
+			}
+			for value := range evicted {
+				_, found := o.Search([]byte(value))
+				So(found, ShouldBeFalse)
+			}
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("objects of other sizes should evict objects of other pools", func() {
+			for i := 0; i < 100; i++ {
+				_, err := o.Add([]byte(fmt.Sprintf("%08d", i)))
+				So(err, ShouldBeNil)
+			}
+			So(o.LenByObjSize(8), ShouldEqual, 100)
+			So(o.mappedBytes(), ShouldBeLessThanOrEqualTo, 3*slabLen100x4)
+			So(o.Verify(), ShouldBeNil)
+		})
+
+	})
+	Convey("When replacing the next victim of a full cache", t, func() {
+		evicted := make(map[string]ObjAddr)
+		o := newCacheTestStore(true, evicted)
+		for i := 0; i < 300; i++ {
+			_, err := o.Add([]byte(fmt.Sprintf("%04d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		// the clock hand starts at the first slot of the first slab
+		pool := o.slabPools[4]
+		victim := pool.slabs[0].addr() + pool.slabs[0].getObjOffset(0)
+		obj, _ := o.Get(victim)
+		value := string(obj)
+		pool.slabs[0].references()[0] = 0
+		expected := value + "x"
+
+		Convey("Replace should not evict the replaced object", func() {
+			addr, err := o.Replace(victim, []byte(expected))
+			So(err, ShouldBeNil)
+			obj, _ := o.Get(addr)
+			So(string(obj), ShouldEqual, expected)
+			So(evicted, ShouldNotContainKey, value)
+			So(o.Len(), ShouldEqual, 300-len(evicted))
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+
+	Convey("When a cache with an ordered index evicts its last object", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(1), WithGrowthFactor(1), WithOrderedIndex(), WithMaxBytes(uint64(pageSize)), WithCache(nil))
+		So(err, ShouldBeNil)
+		_, err = o.Add([]byte("aaaa"))
+		So(err, ShouldBeNil)
+
+		Convey("the added object should still get indexed", func() {
+			addr, err := o.Add([]byte("bbbbbbbb"))
+			So(err, ShouldBeNil)
+			So(o.Len(), ShouldEqual, 1)
+			found, ok := o.Search([]byte("bbbbbbbb"))
+			So(ok, ShouldBeTrue)
+			So(found, ShouldEqual, addr)
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+}
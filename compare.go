@@ -0,0 +1,30 @@
+package gos
+
+import "encoding/binary"
+
+// equalObj returns true if the two given objects are equal
+// Both objects must have the same length
+// The common object sizes are compared as whole words, which the compiler
+// turns into single loads. All other sizes are compared by the runtime's
+// vectorized memequal
+func equalObj(a, b []byte) bool {
+	switch len(a) {
+	case 4:
+		return binary.LittleEndian.Uint32(a) == binary.LittleEndian.Uint32(b)
+	case 8:
+		return binary.LittleEndian.Uint64(a) == binary.LittleEndian.Uint64(b)
+	case 16:
+		return binary.LittleEndian.Uint64(a) == binary.LittleEndian.Uint64(b) &&
+			binary.LittleEndian.Uint64(a[8:]) == binary.LittleEndian.Uint64(b[8:])
+	case 24:
+		return binary.LittleEndian.Uint64(a) == binary.LittleEndian.Uint64(b) &&
+			binary.LittleEndian.Uint64(a[8:]) == binary.LittleEndian.Uint64(b[8:]) &&
+			binary.LittleEndian.Uint64(a[16:]) == binary.LittleEndian.Uint64(b[16:])
+	case 32:
+		return binary.LittleEndian.Uint64(a) == binary.LittleEndian.Uint64(b) &&
+			binary.LittleEndian.Uint64(a[8:]) == binary.LittleEndian.Uint64(b[8:]) &&
+			binary.LittleEndian.Uint64(a[16:]) == binary.LittleEndian.Uint64(b[16:]) &&
+			binary.LittleEndian.Uint64(a[24:]) == binary.LittleEndian.Uint64(b[24:])
+	}
+	return string(a) == string(b)
+}
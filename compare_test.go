@@ -0,0 +1,24 @@
+package gos
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEqualObj(t *testing.T) {
+	Convey("When comparing objects of all sizes, a difference at any position should be detected", t, func() {
+		for size := 1; size <= 40; size++ {
+			a := bytes.Repeat([]byte{'a'}, size)
+			b := bytes.Repeat([]byte{'a'}, size)
+			So(equalObj(a, b), ShouldBeTrue)
+
+			for i := 0; i < size; i++ {
+				b[i] = 'b'
+				So(equalObj(a, b), ShouldBeFalse)
+				b[i] = 'a'
+			}
+		}
+	})
+}
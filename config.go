@@ -1,5 +1,7 @@
 package gos
 
+import "fmt"
+
 // Config provides an ObjectStoreConfig with default settings.
 var Config = NewConfig()
 
@@ -8,7 +10,102 @@ var Config = NewConfig()
 // for more information
 type ObjectStoreConfig struct {
 	BaseObjectsPerSlab uint8
-	GrowthFactor       float64 // for use with math.Pow this is easier
+	GrowthFactor       float64
+
+	// MaxObjectsPerSlab and MaxSlabBytes limit the size of the slabs which
+	// get created once a pool has grown large, 0 means that there is no
+	// limit. A slab always has at least one object slot
+	MaxObjectsPerSlab uint32
+	MaxSlabBytes      uint64
+
+	// Placement selects the slab which gets an added object if several
+	// slabs of its pool have free slots, see Placement
+	Placement Placement
+
+	// ReleasePages makes Delete return the pages of the deleted object to
+	// the operating system once they contain no live objects, see
+	// ReleaseMemory. This costs a madvise syscall per released page range.
+	// The pages of huge page slabs don't get released, see HugePages.
+	// Pages only get released on Linux. The release is best-effort, Delete
+	// doesn't fail if it fails
+	ReleasePages bool
+
+	// HugePages aligns slabs of at least 1MB to 2MB and advises the kernel
+	// to back them with transparent huge pages, which reduces the TLB
+	// misses of random accesses to large stores. Such slabs get as many
+	// objects as fit into their huge pages. If transparent huge pages are
+	// disabled the slabs use normal pages. It can't be used in Debug mode.
+	// The pages of huge page slabs don't get released by ReleaseMemory or
+	// ReleasePages. It gets ignored on platforms other than Linux
+	HugePages bool
+
+	// CollectStats enables the operation counters and latency
+	// histograms which are returned by ObjectStore.Stats
+	CollectStats bool
+
+	// Fingerprints stores a one byte hash of each object next to the
+	// object slots, which makes searches much faster for the cost of
+	// one additional byte per object
+	Fingerprints bool
+
+	// Debug surrounds every slab with inaccessible guard pages, poisons
+	// the slots of deleted objects, makes Get and Delete verify that the
+	// given object is live and quarantines emptied slabs instead of
+	// unmapping them. This makes the use of deleted objects fault
+	// deterministically, but it costs memory and speed, so it is meant
+	// for tests
+	Debug bool
+
+	// QuarantineSlabs is the number of emptied slabs which are kept
+	// mapped, but inaccessible, in debug mode. Once there are more the
+	// oldest ones get unmapped and their address range may get reused.
+	// 0 means that the default of 64 slabs is kept
+	QuarantineSlabs int
+
+	// OrderedIndex maintains a sorted index of all objects on Add and
+	// Delete, which is required by Range and ForEach. It costs 16 bytes
+	// per object and makes Add and Delete slower
+	OrderedIndex bool
+
+	// CuckooFilter maintains a cuckoo filter of the objects of each pool,
+	// which lets searches for most absent objects return without scanning
+	// the slabs. It costs about 2.5 bytes per object, see FilterStatsPerPool
+	CuckooFilter bool
+
+	// SizeClasses enables the size class mode if it is not empty. Objects
+	// get stored in the pool of the smallest class which can hold them,
+	// together with their length, so there are far fewer pools and slabs
+	// for the cost of some unused bytes in each object slot. Objects which
+	// are larger than the largest class get stored in pools of their exact
+	// length. See DefaultSizeClasses
+	SizeClasses []uint8
+
+	// Expiry stores an expiry timestamp of each object in the slabs, which
+	// costs 8 bytes per object. Objects added with AddWithTTL get deleted
+	// by ExpireBefore or by the sweeper which is started by StartSweeper
+	Expiry bool
+
+	// MaxBytes limits the number of bytes which get mmapped for slabs, 0
+	// means that there is no limit. Once the limit has been reached Add
+	// fails, unless the Cache mode is enabled. The limit applies to the
+	// whole mapped regions, including the guard pages and the quarantine
+	// of the debug mode and the rounding up to whole pages or huge pages
+	MaxBytes uint64
+
+	// Cache makes the object store a bounded cache. Once MaxBytes has been
+	// reached Add evicts objects which haven't been used recently, using a
+	// reference bit of each object which gets set by Get and Search (CLOCK).
+	// Objects of the same pool get evicted first. It can't be used in Debug
+	// mode, because evictions can't free the quarantined slabs
+	Cache bool
+
+	// OnEvict gets called with each object before it gets evicted, the
+	// value must not be used after OnEvict has returned
+	OnEvict func(ObjAddr, []byte) `json:"-"`
+
+	// Observer gets notified about created and destroyed slabs and about
+	// added and deleted objects, see Observer. It may be nil
+	Observer Observer `json:"-"`
 }
 
 // NewConfig returns a new object store configuration with
@@ -21,3 +118,95 @@ func NewConfig() ObjectStoreConfig {
 		GrowthFactor:       1.3,
 	}
 }
+
+// maxGrowthFactor is the largest GrowthFactor which Validate accepts
+const maxGrowthFactor = 16
+
+// Validate checks the configuration for degenerate settings
+// On success it returns nil, otherwise it returns an error which describes
+// the first invalid setting
+func (c ObjectStoreConfig) Validate() error {
+	if c.BaseObjectsPerSlab == 0 {
+		return fmt.Errorf("ObjectStoreConfig: BaseObjectsPerSlab must be at least 1")
+	}
+	if !(c.GrowthFactor >= 1 && c.GrowthFactor <= maxGrowthFactor) {
+		return fmt.Errorf("ObjectStoreConfig: GrowthFactor (%g) must be between 1 and %d", c.GrowthFactor, maxGrowthFactor)
+	}
+	if c.Placement > PlacementLargest {
+		return fmt.Errorf("ObjectStoreConfig: Placement (%s) is unknown", c.Placement)
+	}
+	if c.HugePages && c.Debug {
+		return fmt.Errorf("ObjectStoreConfig: HugePages can't be used in Debug mode")
+	}
+	if c.Cache && c.Debug {
+		return fmt.Errorf("ObjectStoreConfig: Cache can't be used in Debug mode")
+	}
+	if c.QuarantineSlabs < 0 {
+		return fmt.Errorf("ObjectStoreConfig: QuarantineSlabs (%d) must not be negative", c.QuarantineSlabs)
+	}
+	if c.Cache && c.MaxBytes == 0 {
+		return fmt.Errorf("ObjectStoreConfig: Cache requires MaxBytes")
+	}
+	if c.OnEvict != nil && !c.Cache {
+		return fmt.Errorf("ObjectStoreConfig: OnEvict requires Cache")
+	}
+	return nil
+}
+
+// SlabSize describes a slab in the sequence which is returned by SlabSizes
+type SlabSize struct {
+	Objects uint
+	Bytes   uintptr
+}
+
+// SlabSizes returns the number of objects and the number of bytes of each
+// of the first n slabs of the pool which stores objects of the given size.
+// Once all slabs of a pool are full, it adds the next slab of the sequence.
+// The sequence assumes that no objects get deleted, after deletes the pool
+// adds smaller slabs because their size depends on the live objects
+func (c ObjectStoreConfig) SlabSizes(objSize uint8, n int) []SlabSize {
+	poolSize := objSize
+	classes := newSizeClassTable(c.SizeClasses)
+	if classes != nil {
+		poolSize = classes.poolSizes[objSize]
+	}
+	flags := c.slabFlagsFor(poolSize, classes)
+
+	sizes := make([]SlabSize, n)
+	var live uint64
+	for i := range sizes {
+		objCount := objCountFor(c.BaseObjectsPerSlab, c.GrowthFactor, live)
+		if c.HugePages && transparentHugePages {
+			objCount = packHugePages(objCount, poolSize, flags)
+		}
+		objCount = capObjCount(objCount, uint(c.MaxObjectsPerSlab), c.MaxSlabBytes, poolSize, flags)
+		sizes[i] = SlabSize{
+			Objects: objCount,
+			Bytes:   slabLenFor(poolSize, objCount, flags),
+		}
+		live += uint64(objCount)
+	}
+	return sizes
+}
+
+// slabFlagsFor returns the flags of the slabs of the pool with the given
+// object size
+func (c ObjectStoreConfig) slabFlagsFor(size uint8, classes *sizeClassTable) uint16 {
+	var flags uint16
+	if c.Fingerprints {
+		flags |= slabFlagFingerprints
+	}
+	if classes.isSizeClass(size) {
+		flags |= slabFlagLengthPrefix
+	}
+	if c.Expiry {
+		flags |= slabFlagExpiry
+	}
+	if c.Cache {
+		flags |= slabFlagClock
+	}
+	if c.Debug {
+		flags |= slabFlagDebug
+	}
+	return flags
+}
@@ -0,0 +1,118 @@
+package gos
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("The default config should be valid", t, func() {
+		So(NewConfig().Validate(), ShouldBeNil)
+		_, err := NewObjectStoreChecked(NewConfig())
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Degenerate configs should be rejected", t, func() {
+		for _, modify := range []func(*ObjectStoreConfig){
+			func(c *ObjectStoreConfig) { c.BaseObjectsPerSlab = 0 },
+			func(c *ObjectStoreConfig) { c.GrowthFactor = 0.9 },
+			func(c *ObjectStoreConfig) { c.GrowthFactor = math.NaN() },
+			func(c *ObjectStoreConfig) { c.GrowthFactor = 1e10 },
+			func(c *ObjectStoreConfig) { c.QuarantineSlabs = -1 },
+			func(c *ObjectStoreConfig) { c.Cache = true },
+			func(c *ObjectStoreConfig) { c.OnEvict = func(ObjAddr, []byte) {} },
+		} {
+			c := NewConfig()
+			modify(&c)
+			So(c.Validate(), ShouldNotBeNil)
+			_, err := NewObjectStoreChecked(c)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestObjCountClamping(t *testing.T) {
+	Convey("Object counts should be clamped to sane bounds", t, func() {
+		So(objCountFor(0, 1.3, 0), ShouldEqual, 1)
+		So(objCountFor(10, 0.5, 20), ShouldEqual, 1)
+		So(objCountFor(10, math.NaN(), 1), ShouldEqual, 1)
+		So(objCountFor(255, 1e10, 10), ShouldEqual, maxObjCountPerSlab)
+		So(objCountFor(255, math.Inf(1), 1), ShouldEqual, maxObjCountPerSlab)
+		So(objCountFor(255, 2, math.MaxUint64), ShouldEqual, maxObjCountPerSlab)
+		So(objCountFor(10, 1.3, 39), ShouldEqual, 21)
+	})
+
+	Convey("Object counts should be capped by the slab limits", t, func() {
+		So(capObjCount(100, 0, 0, 10, 0), ShouldEqual, 100)
+		So(capObjCount(100, 50, 0, 10, 0), ShouldEqual, 50)
+		So(capObjCount(100, 0, 1, 10, 0), ShouldEqual, 1)
+
+		n := capObjCount(1000, 0, 4096, 10, slabFlagFingerprints)
+		So(slabLenFor(10, n, slabFlagFingerprints), ShouldBeLessThanOrEqualTo, 4096)
+		So(slabLenFor(10, n+1, slabFlagFingerprints), ShouldBeGreaterThan, 4096)
+	})
+
+	Convey("A store with a degenerate config should still work", t, func() {
+		c := NewConfig()
+		c.BaseObjectsPerSlab = 0
+		c.GrowthFactor = 0
+		o := NewObjectStore(c)
+		for i := 0; i < 10; i++ {
+			_, err := o.Add([]byte("abc"))
+			So(err, ShouldBeNil)
+		}
+		So(len(o.slabPools[3].slabs), ShouldEqual, 10)
+		So(o.Verify(), ShouldBeNil)
+	})
+}
+
+func TestSlabSizes(t *testing.T) {
+	Convey("SlabSizes should describe the slabs which pools create", t, func() {
+		c := NewConfig()
+		c.BaseObjectsPerSlab = 10
+		c.Fingerprints = true
+		o := NewObjectStore(c)
+		for i := 0; i < 100; i++ {
+			_, err := o.Add([]byte("abcde"))
+			So(err, ShouldBeNil)
+		}
+
+		pool := o.slabPools[5]
+		sizes := c.SlabSizes(5, len(pool.slabs))
+		So(sizes[:4], ShouldResemble, []SlabSize{
+			{10, metadataLenFor(10, slabFlagFingerprints) + 50},
+			{13, metadataLenFor(13, slabFlagFingerprints) + 65},
+			{16, metadataLenFor(16, slabFlagFingerprints) + 80},
+			{21, metadataLenFor(21, slabFlagFingerprints) + 105},
+		})
+		var total uintptr
+		for _, size := range sizes {
+			total += size.Bytes
+		}
+		So(uint64(total), ShouldEqual, pool.mapped)
+	})
+
+	Convey("SlabSizes should stop growing at the slab limits", t, func() {
+		c := NewConfig()
+		c.BaseObjectsPerSlab = 10
+		c.GrowthFactor = 2
+		c.MaxObjectsPerSlab = 100
+		sizes := c.SlabSizes(5, 10)
+		So(sizes[len(sizes)-1].Objects, ShouldEqual, 100)
+
+		c.MaxObjectsPerSlab = 0
+		c.MaxSlabBytes = 1000
+		for _, size := range c.SlabSizes(5, 10) {
+			So(size.Bytes, ShouldBeLessThanOrEqualTo, 1000)
+		}
+	})
+
+	Convey("SlabSizes should take the size classes into account", t, func() {
+		c := NewConfig()
+		c.SizeClasses = []uint8{8, 16}
+		sizes := c.SlabSizes(5, 1)
+		So(sizes[0].Bytes, ShouldEqual, metadataLenFor(25, slabFlagLengthPrefix)+25*9)
+	})
+}
@@ -0,0 +1,76 @@
+package gos
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// defaultQuarantineSlabs is the number of emptied slabs which are kept in
+// the quarantine if the config doesn't set QuarantineSlabs
+const defaultQuarantineSlabs = 64
+
+// quarantine keeps emptied debug slabs mapped, but inaccessible, so that
+// accesses to objects which have been deleted fault instead of reading
+// memory that might have been reused
+type quarantine struct {
+	limit    int
+	mappings [][]byte
+}
+
+// add makes the given slab inaccessible and adds it to the quarantine
+// If the quarantine exceeds its limit the oldest slabs get unmapped
+func (q *quarantine) add(s *slab) error {
+	mapping := s.mapping()
+	err := mprotect(s.region(), syscall.PROT_NONE)
+	if err != nil {
+		return err
+	}
+	q.mappings = append(q.mappings, mapping)
+
+	for len(q.mappings) > q.limit {
+		err = syscall.Munmap(q.mappings[0])
+		if err != nil {
+			return err
+		}
+		q.mappings[0] = nil
+		q.mappings = q.mappings[1:]
+	}
+
+	return nil
+}
+
+// mappedBytes returns the number of bytes of the quarantined slabs, which
+// are still mapped
+func (q *quarantine) mappedBytes() uint64 {
+	var total uint64
+	for _, mapping := range q.mappings {
+		total += uint64(len(mapping))
+	}
+	return total
+}
+
+// poison overwrites the given object with the poison byte
+func poison(obj []byte) {
+	for i := range obj {
+		obj[i] = poisonByte
+	}
+}
+
+// checkObjAddr verifies that the given object address refers to a live
+// object in the slab at the given slab address
+// On success it returns nil, otherwise it returns an error that describes
+// why the object address isn't valid
+func checkObjAddr(obj ObjAddr, sAddr SlabAddr) error {
+	s := slabFromSlabAddr(sAddr)
+	if !s.contains(obj) {
+		return fmt.Errorf("ObjectStore: object address 0x%x is not inside of slab 0x%x", obj, sAddr)
+	}
+	dataStart := sAddr + s.getDataOffset() + s.prefixLen()
+	if (obj-dataStart)%s.slotSize() != 0 {
+		return fmt.Errorf("ObjectStore: object address 0x%x is not the start of an object slot", obj)
+	}
+	if !s.isUsed(s.getObjIdx(obj)) {
+		return fmt.Errorf("ObjectStore: object address 0x%x refers to a deleted object", obj)
+	}
+	return nil
+}
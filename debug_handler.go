@@ -0,0 +1,266 @@
+package gos
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+)
+
+// defaultDebugSlotLimit is the default number of object slots which
+// get rendered when drilling into a slab
+const defaultDebugSlotLimit = 1000
+
+// debugSlab describes a slab in the output of the debug handler
+type debugSlab struct {
+	Addr     string  `json:"addr"`
+	ObjSize  uint8   `json:"objSize"`
+	ObjCount uint    `json:"objCount"`
+	Used     uint32  `json:"used"`
+	Fill     float64 `json:"fill"`
+	Bytes    uintptr `json:"bytes"`
+}
+
+// debugPool describes a slab pool in the output of the debug handler
+type debugPool struct {
+	ObjSize     uint8       `json:"objSize"`
+	Objects     uint64      `json:"objects"`
+	Capacity    uint64      `json:"capacity"`
+	MappedBytes uint64      `json:"mappedBytes"`
+	FragRatio   float64     `json:"fragRatio"`
+	Slabs       []debugSlab `json:"slabs"`
+
+	// PredictedGrowth is the number of objects of each slab as predicted
+	// by ObjectStoreConfig.SlabSizes for an empty pool, including the next
+	// slab. After deletes the actual slabs are smaller, see Slabs
+	PredictedGrowth []uint `json:"predictedGrowth"`
+}
+
+// debugConfig is the config of an object store in the output of the debug
+// handler, it lists the size classes as numbers because encoding/json
+// turns a []uint8 into a base64 string
+type debugConfig struct {
+	ObjectStoreConfig
+	SizeClasses []uint
+}
+
+// debugStore describes an object store in the output of the debug handler
+type debugStore struct {
+	Config      debugConfig `json:"config"`
+	Objects     int         `json:"objects"`
+	MappedBytes uint64      `json:"mappedBytes"`
+	FragRatio   float64     `json:"fragRatio"`
+	Pools       []debugPool `json:"pools"`
+}
+
+// debugSlot describes an object slot in the output of the debug handler
+type debugSlot struct {
+	Index uint   `json:"index"`
+	Addr  string `json:"addr"`
+	Used  bool   `json:"used"`
+	Data  string `json:"data"`
+}
+
+// debugSlabDetail describes a slab including its bitmap and object slots
+type debugSlabDetail struct {
+	debugSlab
+	Version uint8       `json:"version"`
+	Bitmap  []string    `json:"bitmap"`
+	Slots   []debugSlot `json:"slots"`
+}
+
+// DebugHandler returns an http.Handler which renders the pools and slabs
+// of the given object store, similar to the handlers of net/http/pprof.
+// The following query parameters are supported:
+// format=json renders the output as JSON instead of plain text
+// slab=<addr> renders the bitmap and a hex dump of the objects of one slab
+// offset=<n>&limit=<n> select the object slots which get rendered for a slab
+//
+// The object store is not safe for concurrent use, so mu gets locked while
+// a request reads from the store. It may be nil if the store never gets
+// modified while the handler is in use
+func DebugHandler(o *ObjectStore, mu sync.Locker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mu != nil {
+			mu.Lock()
+			defer mu.Unlock()
+		}
+
+		query := r.URL.Query()
+		asJSON := query.Get("format") == "json"
+
+		if addrParam := query.Get("slab"); addrParam != "" {
+			addr, err := strconv.ParseUint(addrParam, 0, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid slab address %q", addrParam), http.StatusBadRequest)
+				return
+			}
+			s, ok := o.slabByAddr(SlabAddr(addr))
+			if !ok {
+				http.Error(w, fmt.Sprintf("unknown slab address %q", addrParam), http.StatusNotFound)
+				return
+			}
+			offset, _ := strconv.ParseUint(query.Get("offset"), 10, 64)
+			limit, err := strconv.ParseUint(query.Get("limit"), 10, 64)
+			if err != nil {
+				limit = defaultDebugSlotLimit
+			}
+
+			detail := debugSlabDetailFor(s, uint(offset), uint(limit))
+			if asJSON {
+				writeDebugJSON(w, detail)
+			} else {
+				writeDebugSlabText(w, detail)
+			}
+			return
+		}
+
+		store := o.debugStore()
+		if asJSON {
+			writeDebugJSON(w, store)
+		} else {
+			writeDebugStoreText(w, store)
+		}
+	})
+}
+
+// slabByAddr returns the slab at the given address if it is part of this
+// object store
+func (o *ObjectStore) slabByAddr(addr SlabAddr) (*slab, bool) {
+	idx := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] <= addr })
+	if idx >= len(o.lookupTable) || o.lookupTable[idx] != addr {
+		return nil, false
+	}
+	return slabFromSlabAddr(addr), true
+}
+
+func (o *ObjectStore) debugStore() debugStore {
+	total := o.FragReportTotal()
+	res := debugStore{
+		Config:      debugConfig{ObjectStoreConfig: o.config},
+		Objects:     o.Len(),
+		MappedBytes: total.MappedBytes,
+		FragRatio:   total.FragRatio,
+	}
+	for _, class := range o.config.SizeClasses {
+		res.Config.SizeClasses = append(res.Config.SizeClasses, uint(class))
+	}
+
+	sizes := make([]int, 0, len(o.slabPools))
+	for size := range o.slabPools {
+		sizes = append(sizes, int(size))
+	}
+	sort.Ints(sizes)
+
+	for _, size := range sizes {
+		pool := o.slabPools[uint8(size)]
+		p := debugPool{
+			ObjSize:     pool.objSize,
+			Objects:     pool.objects,
+			Capacity:    pool.capacity,
+			MappedBytes: pool.mapped,
+			FragRatio:   pool.fragReport().FragRatio,
+		}
+
+		for _, size := range o.config.SlabSizes(pool.objSize, len(pool.slabs)+1) {
+			p.PredictedGrowth = append(p.PredictedGrowth, size.Objects)
+		}
+
+		for _, s := range pool.slabs {
+			p.Slabs = append(p.Slabs, debugSlabFor(s))
+		}
+		res.Pools = append(res.Pools, p)
+	}
+
+	return res
+}
+
+func debugSlabFor(s *slab) debugSlab {
+	return debugSlab{
+		Addr:     fmt.Sprintf("0x%x", s.addr()),
+		ObjSize:  s.objSize,
+		ObjCount: s.objCount(),
+		Used:     s.live,
+		Fill:     float64(s.live) / float64(s.objCount()),
+		Bytes:    s.getTotalLength(),
+	}
+}
+
+func debugSlabDetailFor(s *slab, offset, limit uint) debugSlabDetail {
+	res := debugSlabDetail{
+		debugSlab: debugSlabFor(s),
+		Version:   s.version,
+	}
+	for _, word := range s.bitmap() {
+		res.Bitmap = append(res.Bitmap, fmt.Sprintf("%064b", word))
+	}
+	for idx := offset; idx < s.objCount() && idx-offset < limit; idx++ {
+		obj := s.getObjByIdx(idx)
+		res.Slots = append(res.Slots, debugSlot{
+			Index: idx,
+			Addr:  fmt.Sprintf("0x%x", objAddrFromObj(obj)),
+			Used:  s.isUsed(idx),
+			Data:  hex.EncodeToString(obj),
+		})
+	}
+	return res
+}
+
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeDebugStoreText(w http.ResponseWriter, store debugStore) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "ObjectStore\n")
+	fmt.Fprintf(w, "Base Objects Per Slab: %d\n", store.Config.BaseObjectsPerSlab)
+	fmt.Fprintf(w, "Growth Factor: %g\n", store.Config.GrowthFactor)
+	fmt.Fprintf(w, "Objects: %d\n", store.Objects)
+	fmt.Fprintf(w, "Mapped Bytes: %d\n", store.MappedBytes)
+	fmt.Fprintf(w, "Frag Ratio: %.4f\n", store.FragRatio)
+
+	for _, p := range store.Pools {
+		fmt.Fprintf(w, "\nPool ObjSize: %d\n", p.ObjSize)
+		fmt.Fprintf(w, "Objects: %d/%d\n", p.Objects, p.Capacity)
+		fmt.Fprintf(w, "Mapped Bytes: %d\n", p.MappedBytes)
+		fmt.Fprintf(w, "Frag Ratio: %.4f\n", p.FragRatio)
+		fmt.Fprintf(w, "Predicted Growth (empty pool): %v\n", p.PredictedGrowth)
+
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "Slab Addr\tObjects\tUsed\tFill\tBytes\n")
+		for _, s := range p.Slabs {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%d\n", s.Addr, s.ObjCount, s.Used, s.Fill*100, s.Bytes)
+		}
+		tw.Flush()
+	}
+}
+
+func writeDebugSlabText(w http.ResponseWriter, detail debugSlabDetail) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Slab Addr: %s\n", detail.Addr)
+	fmt.Fprintf(w, "Header Version: %d\n", detail.Version)
+	fmt.Fprintf(w, "Object Size: %d\n", detail.ObjSize)
+	fmt.Fprintf(w, "Objects: %d/%d\n", detail.Used, detail.ObjCount)
+	fmt.Fprintf(w, "Bytes: %d\n", detail.Bytes)
+
+	for i, word := range detail.Bitmap {
+		fmt.Fprintf(w, "bitmap[%d]: %s\n", i, word)
+	}
+
+	for _, slot := range detail.Slots {
+		used := "free"
+		if slot.Used {
+			used = "used"
+		}
+		fmt.Fprintf(w, "slot %d %s %s: %s\n", slot.Index, slot.Addr, used, slot.Data)
+	}
+}
@@ -0,0 +1,92 @@
+package gos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDebugHandler(t *testing.T) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 4
+	c.GrowthFactor = 2
+
+	Convey("When serving the debug handler of an object store", t, func() {
+		os := NewObjectStore(c)
+		for i := 0; i < 5; i++ {
+			_, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+		}
+		handler := DebugHandler(&os, &sync.Mutex{})
+
+		get := func(url string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+			return rec
+		}
+
+		Convey("the overview should list the pools and slabs", func() {
+			rec := get("/")
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			So(rec.Body.String(), ShouldContainSubstring, "Pool ObjSize: 3")
+			So(rec.Body.String(), ShouldContainSubstring, "Predicted Growth (empty pool): [4 8 16]")
+			So(rec.Body.String(), ShouldContainSubstring, fmt.Sprintf("0x%x", os.lookupTable[0]))
+		})
+
+		Convey("the JSON variant should be decodable", func() {
+			rec := get("/?format=json")
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			var store debugStore
+			So(json.Unmarshal(rec.Body.Bytes(), &store), ShouldBeNil)
+			So(store.Objects, ShouldEqual, 5)
+			So(len(store.Pools), ShouldEqual, 1)
+			So(len(store.Pools[0].Slabs), ShouldEqual, 2)
+			So(store.Pools[0].PredictedGrowth, ShouldResemble, []uint{4, 8, 16})
+		})
+
+		Convey("we should be able to drill into a slab", func() {
+			addr := os.slabPools[3].slabs[0].addr()
+			rec := get(fmt.Sprintf("/?slab=0x%x&format=json", addr))
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			var detail debugSlabDetail
+			So(json.Unmarshal(rec.Body.Bytes(), &detail), ShouldBeNil)
+			So(detail.Version, ShouldEqual, slabVersion)
+			So(len(detail.Bitmap), ShouldEqual, 1)
+			So(len(detail.Slots), ShouldEqual, detail.ObjCount)
+
+			rec = get(fmt.Sprintf("/?slab=%d&limit=1", addr))
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			So(strings.Count(rec.Body.String(), "slot "), ShouldEqual, 1)
+		})
+
+		Convey("unknown slab addresses should be rejected", func() {
+			So(get("/?slab=0x10").Code, ShouldEqual, http.StatusNotFound)
+			So(get("/?slab=abc").Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+
+	Convey("The JSON variant should list the size classes as numbers", t, func() {
+		c := NewConfig()
+		c.SizeClasses = []uint8{8, 16, 32}
+		os := NewObjectStore(c)
+		_, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+
+		rec := httptest.NewRecorder()
+		DebugHandler(&os, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=json", nil))
+		So(rec.Code, ShouldEqual, http.StatusOK)
+		var store struct {
+			Config struct {
+				SizeClasses []uint
+			}
+		}
+		So(json.Unmarshal(rec.Body.Bytes(), &store), ShouldBeNil)
+		So(store.Config.SizeClasses, ShouldResemble, []uint{8, 16, 32})
+	})
+}
@@ -0,0 +1,103 @@
+package gos
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"unsafe"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sink prevents the compiler from optimizing away the reads in tests
+var sink byte
+
+// faults calls f and returns true if it caused a memory fault
+func faults(f func()) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		faulted = recover() != nil
+	}()
+	f()
+	return false
+}
+
+func TestDebugMode(t *testing.T) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 4
+	c.GrowthFactor = 1
+	c.Debug = true
+	c.QuarantineSlabs = 1
+
+	Convey("When using an object store in debug mode", t, func() {
+		os := NewObjectStore(c)
+		addr1, err := os.Add([]byte("aaaaa"))
+		So(err, ShouldBeNil)
+		addr2, err := os.Add([]byte("bbbbb"))
+		So(err, ShouldBeNil)
+		obj1, err := os.Get(addr1)
+		So(err, ShouldBeNil)
+		So(os.Verify(), ShouldBeNil)
+
+		Convey("deleted objects should be poisoned and rejected", func() {
+			So(os.Delete(addr1), ShouldBeNil)
+			So(obj1, ShouldResemble, []byte{poisonByte, poisonByte, poisonByte, poisonByte, poisonByte})
+
+			_, err := os.Get(addr1)
+			So(err, ShouldNotBeNil)
+			So(os.Delete(addr1), ShouldNotBeNil)
+			_, err = os.Get(addr2 + 1)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("accessing objects of an emptied slab should fault", func() {
+			So(os.Delete(addr1), ShouldBeNil)
+			So(os.Delete(addr2), ShouldBeNil)
+			So(len(os.quarantine.mappings), ShouldEqual, 1)
+			So(faults(func() { sink = obj1[0] }), ShouldBeTrue)
+
+			_, err := os.Get(addr1)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("accessing memory past the last object of a slab should fault", func() {
+			s := os.slabPools[5].slabs[0]
+			end := s.addr() + s.getTotalLength()
+			So(faults(func() { sink = *(*byte)(unsafe.Pointer(end - 1)) }), ShouldBeFalse)
+			So(faults(func() { sink = *(*byte)(unsafe.Pointer(end + 8)) }), ShouldBeTrue)
+		})
+
+		Convey("accessing memory before the slab header should fault", func() {
+			s := os.slabPools[5].slabs[0]
+			So(faults(func() { sink = *(*byte)(unsafe.Pointer(s.addr() - pageSize)) }), ShouldBeTrue)
+		})
+
+		Convey("without a configured quarantine size emptied slabs should be quarantined", func() {
+			c := c
+			c.QuarantineSlabs = 0
+			os := NewObjectStore(c)
+			addr, err := os.Add([]byte("ddddd"))
+			So(err, ShouldBeNil)
+			obj, err := os.Get(addr)
+			So(err, ShouldBeNil)
+			So(os.Delete(addr), ShouldBeNil)
+
+			// new slabs must not reuse the address range of the emptied one
+			for i := 0; i < 10; i++ {
+				_, err = os.Add([]byte(fmt.Sprintf("%05d", i)))
+				So(err, ShouldBeNil)
+			}
+			So(len(os.quarantine.mappings), ShouldEqual, 1)
+			So(faults(func() { sink = obj[0] }), ShouldBeTrue)
+		})
+
+		Convey("the quarantine should only keep the configured number of slabs", func() {
+			other, err := os.Add([]byte("ccc"))
+			So(err, ShouldBeNil)
+			So(os.Delete(addr1), ShouldBeNil)
+			So(os.Delete(addr2), ShouldBeNil)
+			So(os.Delete(other), ShouldBeNil)
+			So(len(os.quarantine.mappings), ShouldEqual, 1)
+		})
+	})
+}
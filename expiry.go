@@ -0,0 +1,138 @@
+package gos
+
+import (
+	"fmt"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// AddWithTTL is like Add, but the added object expires after the given
+// duration. Expired objects stay in the store until they get deleted by
+// ExpireBefore or by the sweeper, see StartSweeper
+// AddWithTTL requires the Expiry to be enabled in the ObjectStoreConfig
+func (o *ObjectStore) AddWithTTL(obj []byte, ttl time.Duration) (ObjAddr, error) {
+	if !o.config.Expiry {
+		return 0, fmt.Errorf("ObjectStore: AddWithTTL requires the Expiry to be enabled")
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("ObjectStore: AddWithTTL failed because the ttl (%s) is not positive", ttl)
+	}
+	return o.add(obj, time.Now().Add(ttl).UnixNano())
+}
+
+// ExpiresAt returns the time at which the object at the given address
+// expires. It returns the zero time if the object doesn't expire
+func (o *ObjectStore) ExpiresAt(obj ObjAddr) (time.Time, error) {
+	sAddr, err := o.getSlabAddress(obj)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if o.config.Debug {
+		if err = checkObjAddr(obj, sAddr); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	s := slabFromSlabAddr(sAddr)
+	if s.flags&slabFlagExpiry == 0 {
+		return time.Time{}, nil
+	}
+	expiry := s.expiries()[s.getObjIdx(obj)]
+	if expiry == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, expiry), nil
+}
+
+// ExpireBefore deletes all objects which expire before the given time,
+// one slab at a time. It returns the number of deleted objects
+// On failure it returns the number of objects which have been deleted
+// before the error occurred and the error
+func (o *ObjectStore) ExpireBefore(t time.Time) (int, error) {
+	if !o.config.Expiry {
+		return 0, fmt.Errorf("ObjectStore: ExpireBefore requires the Expiry to be enabled")
+	}
+	deadline := t.UnixNano()
+
+	// deleting objects can delete slabs and modify the lookup table, so
+	// we iterate over a copy of it
+	slabs := make([]SlabAddr, len(o.lookupTable))
+	copy(slabs, o.lookupTable)
+
+	var removed int
+	var expired []ObjAddr
+	for _, sAddr := range slabs {
+		s := slabFromSlabAddr(sAddr)
+		if s.flags&slabFlagExpiry == 0 {
+			continue
+		}
+
+		expired = expired[:0]
+		expiries := s.expiries()
+		for i, word := range s.bitmap() {
+			for word != 0 {
+				idx := uint(i)*64 + uint(bits.TrailingZeros64(word))
+				word &= word - 1
+				if expiry := expiries[idx]; expiry != 0 && expiry < deadline {
+					expired = append(expired, sAddr+s.getObjOffset(idx))
+				}
+			}
+		}
+
+		// once the last object of the slab got deleted the slab is gone
+		for _, obj := range expired {
+			if err := o.Delete(obj); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// StartSweeper starts a goroutine which deletes the expired objects at the
+// given interval by calling ExpireBefore. The object store is not safe for
+// concurrent use, so mu gets locked during each sweep. Like for DebugHandler
+// mu may be nil, then the sweeps don't lock anything, which is only safe if
+// the store isn't used by other goroutines meanwhile. After each sweep
+// onSweep gets called with the results of ExpireBefore, unless it is nil.
+// The returned function stops the sweeper and waits until it has stopped
+func (o *ObjectStore) StartSweeper(interval time.Duration, mu sync.Locker, onSweep func(removed int, err error)) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if mu != nil {
+					mu.Lock()
+				}
+				removed, err := o.ExpireBefore(now)
+				if mu != nil {
+					mu.Unlock()
+				}
+				if onSweep != nil {
+					onSweep(removed, err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package gos
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExpiry(t *testing.T) {
+	Convey("When adding objects with and without a TTL", t, func() {
+		c := NewConfig()
+		c.Expiry = true
+		o := NewObjectStore(c)
+
+		now := time.Now()
+		var permanent []ObjAddr
+		for i := 0; i < 300; i++ {
+			value := []byte(fmt.Sprintf("%04d", i))
+			var addr ObjAddr
+			var err error
+			switch i % 3 {
+			case 0:
+				addr, err = o.Add(value)
+				permanent = append(permanent, addr)
+			case 1:
+				addr, err = o.AddWithTTL(value, time.Minute)
+			case 2:
+				addr, err = o.AddWithTTL(value, time.Hour)
+			}
+			So(err, ShouldBeNil)
+		}
+		So(o.Verify(), ShouldBeNil)
+
+		Convey("ExpiresAt should return the expiry time", func() {
+			expiresAt, err := o.ExpiresAt(permanent[0])
+			So(err, ShouldBeNil)
+			So(expiresAt.IsZero(), ShouldBeTrue)
+
+			addr, _ := o.Search([]byte("0001"))
+			expiresAt, err = o.ExpiresAt(addr)
+			So(err, ShouldBeNil)
+			So(expiresAt, ShouldHappenBetween, now.Add(time.Minute), time.Now().Add(time.Minute))
+		})
+
+		Convey("ExpireBefore should only delete the expired objects", func() {
+			removed, err := o.ExpireBefore(time.Now().Add(2 * time.Minute))
+			So(err, ShouldBeNil)
+			So(removed, ShouldEqual, 100)
+			So(o.Len(), ShouldEqual, 200)
+			_, found := o.Search([]byte("0001"))
+			So(found, ShouldBeFalse)
+			_, found = o.Search([]byte("0002"))
+			So(found, ShouldBeTrue)
+			So(o.Verify(), ShouldBeNil)
+
+			removed, err = o.ExpireBefore(time.Now().Add(2 * time.Hour))
+			So(err, ShouldBeNil)
+			So(removed, ShouldEqual, 100)
+			So(o.Len(), ShouldEqual, 100)
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("reused slots should not keep the expiry of deleted objects", func() {
+			_, err := o.ExpireBefore(time.Now().Add(2 * time.Hour))
+			So(err, ShouldBeNil)
+			for i := 0; i < 200; i++ {
+				_, err = o.Add([]byte(fmt.Sprintf("%04d", i+1000)))
+				So(err, ShouldBeNil)
+			}
+			removed, err := o.ExpireBefore(time.Now().Add(2 * time.Hour))
+			So(err, ShouldBeNil)
+			So(removed, ShouldEqual, 0)
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("a moved object should keep its expiry time", func() {
+			addr, _ := o.Search([]byte("0001"))
+			expiresAt, _ := o.ExpiresAt(addr)
+			addr, err := o.Replace(addr, []byte("00001"))
+			So(err, ShouldBeNil)
+			movedExpiresAt, err := o.ExpiresAt(addr)
+			So(err, ShouldBeNil)
+			So(movedExpiresAt.Equal(expiresAt), ShouldBeTrue)
+		})
+	})
+
+	Convey("When the sweeper is running", t, func() {
+		c := NewConfig()
+		c.Expiry = true
+		o := NewObjectStore(c)
+		var mu sync.Mutex
+
+		for i := 0; i < 100; i++ {
+			_, err := o.AddWithTTL([]byte(fmt.Sprintf("%04d", i)), time.Millisecond)
+			So(err, ShouldBeNil)
+		}
+
+		removedCh := make(chan int, 100)
+		errCh := make(chan error, 100)
+		stop := o.StartSweeper(5*time.Millisecond, &mu, func(removed int, err error) {
+			removedCh <- removed
+			errCh <- err
+		})
+
+		Convey("it should delete the expired objects", func() {
+			total := 0
+			for total < 100 {
+				total += <-removedCh
+				So(<-errCh, ShouldBeNil)
+			}
+			stop()
+			stop()
+
+			mu.Lock()
+			So(o.Len(), ShouldEqual, 0)
+			So(o.lookupTable, ShouldBeEmpty)
+			mu.Unlock()
+		})
+	})
+
+	Convey("When the sweeper is running without a lock", t, func() {
+		c := NewConfig()
+		c.Expiry = true
+		o := NewObjectStore(c)
+		_, err := o.AddWithTTL([]byte("abc"), time.Millisecond)
+		So(err, ShouldBeNil)
+
+		errCh := make(chan error, 100)
+		stop := o.StartSweeper(5*time.Millisecond, nil, func(removed int, err error) {
+			errCh <- err
+		})
+
+		Convey("it should sweep without locking", func() {
+			So(<-errCh, ShouldBeNil)
+			stop()
+			So(o.Len(), ShouldEqual, 0)
+		})
+	})
+
+	Convey("When the Expiry is disabled", t, func() {
+		o := NewObjectStore(NewConfig())
+		_, err := o.AddWithTTL([]byte("abc"), time.Minute)
+		So(err, ShouldNotBeNil)
+		_, err = o.ExpireBefore(time.Now())
+		So(err, ShouldNotBeNil)
+	})
+}
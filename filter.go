@@ -0,0 +1,280 @@
+package gos
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	// cuckooBucketSize is the number of 16 bit fingerprints per bucket,
+	// each bucket is stored in one uint64
+	cuckooBucketSize = 4
+
+	// cuckooMinBuckets is the number of buckets of a new filter
+	cuckooMinBuckets = 16
+
+	// cuckooMaxLoad is the fraction of used fingerprint slots at which the
+	// filter gets rebuilt with twice as many buckets
+	cuckooMaxLoad = 0.9
+
+	// cuckooMaxKicks is the number of fingerprints which get relocated
+	// before an insert gives up and stashes the fingerprint
+	cuckooMaxKicks = 500
+)
+
+// FilterStat stores statistics about the cuckoo filter of a slab pool
+type FilterStat struct {
+	ObjSize uint8
+	Items   uint64 // number of fingerprints in the filter
+	Bytes   uint64 // memory used by the filter
+	Load    float64
+
+	// FalsePositiveRate is the estimated probability that the filter
+	// doesn't rule out a search for an absent object
+	FalsePositiveRate float64
+
+	Negatives      uint64 // number of searches which were answered by the filter
+	FalsePositives uint64 // number of searches which passed the filter, but didn't find the object
+}
+
+// cuckooItem is a fingerprint which didn't fit into its buckets
+type cuckooItem struct {
+	fp     uint16
+	bucket uint64
+}
+
+// cuckooFilter is a probabilistic set of object hashes, which unlike a
+// Bloom filter supports deletes. It is used to rule out searches for
+// objects which aren't in a slab pool without scanning its slabs
+type cuckooFilter struct {
+	buckets []uint64
+	mask    uint64
+	count   uint64
+
+	// stash holds the fingerprints which couldn't be placed, this only
+	// happens if more than 2*cuckooBucketSize objects share a fingerprint
+	// and its buckets, for example because they are equal
+	stash []cuckooItem
+
+	// kicks is the state of the xorshift generator which picks the
+	// fingerprints that get relocated
+	kicks uint64
+
+	// the counters are updated by searches, which may run concurrently
+	negatives      uint64
+	falsePositives uint64
+}
+
+// newCuckooFilter returns a new, empty filter with the given number of
+// buckets, which must be a power of 2
+func newCuckooFilter(buckets int) *cuckooFilter {
+	return &cuckooFilter{
+		buckets: make([]uint64, buckets),
+		mask:    uint64(buckets - 1),
+		kicks:   0x9e3779b97f4a7c15,
+	}
+}
+
+// fingerprintAndBucket derives the 16 bit fingerprint, which is never 0,
+// and the first bucket of an object from its hash
+func (f *cuckooFilter) fingerprintAndBucket(h uint64) (uint16, uint64) {
+	fp := uint16(h >> 48)
+	if fp == 0 {
+		fp = 1
+	}
+	return fp, h & f.mask
+}
+
+// altBucket returns the other bucket of a fingerprint, given one of them
+func (f *cuckooFilter) altBucket(bucket uint64, fp uint16) uint64 {
+	return (bucket ^ (uint64(fp) * 0x5bd1e995)) & f.mask
+}
+
+// bucketHas returns true if the given bucket contains the fingerprint
+func (f *cuckooFilter) bucketHas(bucket uint64, fp uint16) bool {
+	word := f.buckets[bucket]
+	for i := 0; i < cuckooBucketSize; i++ {
+		if uint16(word>>(16*i)) == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketPut stores the fingerprint in a free slot of the given bucket
+// It returns false if the bucket is full
+func (f *cuckooFilter) bucketPut(bucket uint64, fp uint16) bool {
+	word := f.buckets[bucket]
+	for i := 0; i < cuckooBucketSize; i++ {
+		if uint16(word>>(16*i)) == 0 {
+			f.buckets[bucket] = word | uint64(fp)<<(16*i)
+			return true
+		}
+	}
+	return false
+}
+
+// bucketRemove removes one instance of the fingerprint from the given
+// bucket, it returns false if the bucket doesn't contain it
+func (f *cuckooFilter) bucketRemove(bucket uint64, fp uint16) bool {
+	word := f.buckets[bucket]
+	for i := 0; i < cuckooBucketSize; i++ {
+		if uint16(word>>(16*i)) == fp {
+			f.buckets[bucket] = word &^ (0xffff << (16 * i))
+			return true
+		}
+	}
+	return false
+}
+
+// insert adds the object with the given hash to the filter
+func (f *cuckooFilter) insert(h uint64) {
+	f.count++
+	fp, bucket := f.fingerprintAndBucket(h)
+	if f.bucketPut(bucket, fp) {
+		return
+	}
+	bucket = f.altBucket(bucket, fp)
+	if f.bucketPut(bucket, fp) {
+		return
+	}
+
+	// both buckets are full, so we relocate random fingerprints to their
+	// other buckets until one of them finds a free slot
+	for i := 0; i < cuckooMaxKicks; i++ {
+		f.kicks ^= f.kicks << 13
+		f.kicks ^= f.kicks >> 7
+		f.kicks ^= f.kicks << 17
+		shift := 16 * (f.kicks % cuckooBucketSize)
+
+		word := f.buckets[bucket]
+		victim := uint16(word >> shift)
+		f.buckets[bucket] = word&^(0xffff<<shift) | uint64(fp)<<shift
+
+		fp = victim
+		bucket = f.altBucket(bucket, fp)
+		if f.bucketPut(bucket, fp) {
+			return
+		}
+	}
+
+	f.stash = append(f.stash, cuckooItem{fp: fp, bucket: bucket})
+}
+
+// remove removes the object with the given hash from the filter
+// The object must have been inserted before
+func (f *cuckooFilter) remove(h uint64) {
+	fp, bucket := f.fingerprintAndBucket(h)
+	alt := f.altBucket(bucket, fp)
+	if f.bucketRemove(bucket, fp) || f.bucketRemove(alt, fp) {
+		f.count--
+		return
+	}
+	for i, item := range f.stash {
+		if item.fp == fp && (item.bucket == bucket || item.bucket == alt) {
+			f.stash[i] = f.stash[len(f.stash)-1]
+			f.stash = f.stash[:len(f.stash)-1]
+			f.count--
+			return
+		}
+	}
+}
+
+// contains returns false if the object with the given hash is certainly
+// not in the filter
+func (f *cuckooFilter) contains(h uint64) bool {
+	fp, bucket := f.fingerprintAndBucket(h)
+	alt := f.altBucket(bucket, fp)
+	if f.bucketHas(bucket, fp) || f.bucketHas(alt, fp) {
+		return true
+	}
+	for _, item := range f.stash {
+		if item.fp == fp && (item.bucket == bucket || item.bucket == alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// full returns true if the filter should be rebuilt before it gets
+// another fingerprint
+func (f *cuckooFilter) full() bool {
+	return float64(f.count+1) > cuckooMaxLoad*float64(len(f.buckets)*cuckooBucketSize)
+}
+
+// stat returns the statistics of this filter
+func (f *cuckooFilter) stat(objSize uint8) FilterStat {
+	load := float64(f.count) / float64(len(f.buckets)*cuckooBucketSize)
+	return FilterStat{
+		ObjSize: objSize,
+		Items:   f.count,
+		Bytes:   uint64(len(f.buckets))*8 + uint64(len(f.stash))*uint64(unsafe.Sizeof(cuckooItem{})),
+		Load:    load,
+		// a lookup compares the fingerprint to up to 2*cuckooBucketSize
+		// fingerprints, each of which is used with the probability load
+		FalsePositiveRate: 1 - math.Pow(1-1/65536.0, 2*cuckooBucketSize*load),
+		Negatives:         atomic.LoadUint64(&f.negatives),
+		FalsePositives:    atomic.LoadUint64(&f.falsePositives),
+	}
+}
+
+// filterAdd adds the given object, which has just been added to the pool,
+// to the filter of the pool. If the filter is too full it gets rebuilt
+// with twice as many buckets
+func (s *slabPool) filterAdd(obj []byte) {
+	if s.filter == nil {
+		return
+	}
+	if s.filter.full() {
+		s.rebuildFilter(2 * len(s.filter.buckets))
+		return
+	}
+	s.filter.insert(objHash(obj))
+}
+
+// rebuildFilter replaces the filter of the pool with one of the given
+// number of buckets, which contains all objects of the pool
+func (s *slabPool) rebuildFilter(buckets int) {
+	filter := newCuckooFilter(buckets)
+	if s.filter != nil {
+		filter.negatives = atomic.LoadUint64(&s.filter.negatives)
+		filter.falsePositives = atomic.LoadUint64(&s.filter.falsePositives)
+	}
+	for _, currentSlab := range s.slabs {
+		for i, word := range currentSlab.bitmap() {
+			for word != 0 {
+				idx := uint(i)*64 + uint(bits.TrailingZeros64(word))
+				word &= word - 1
+				filter.insert(objHash(currentSlab.getObjByIdx(idx)))
+			}
+		}
+	}
+	s.filter = filter
+}
+
+// FilterStatsByObjSize returns the statistics of the cuckoo filter of the
+// slab pool with the given object size
+func (o *ObjectStore) FilterStatsByObjSize(size uint8) (FilterStat, error) {
+	pool, ok := o.slabPools[size]
+	if !ok {
+		return FilterStat{}, fmt.Errorf("ObjectStore: FilterStatsByObjSize failed to find pool with object size %d", size)
+	}
+	if pool.filter == nil {
+		return FilterStat{}, fmt.Errorf("ObjectStore: FilterStatsByObjSize requires the CuckooFilter to be enabled")
+	}
+	return pool.filter.stat(size), nil
+}
+
+// FilterStatsPerPool returns a slice containing a FilterStat for each
+// slab pool, it is empty if the CuckooFilter isn't enabled
+func (o *ObjectStore) FilterStatsPerPool() (filterStats []FilterStat) {
+	for _, p := range o.slabPools {
+		if p.filter != nil {
+			filterStats = append(filterStats, p.filter.stat(p.objSize))
+		}
+	}
+	return
+}
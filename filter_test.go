@@ -0,0 +1,179 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCuckooFilter(t *testing.T) {
+	Convey("When inserting hashes into a cuckoo filter", t, func() {
+		f := newCuckooFilter(1024)
+		for i := 0; i < 3000; i++ {
+			f.insert(objHash([]byte(fmt.Sprintf("%d", i))))
+		}
+
+		Convey("it should contain all of them", func() {
+			So(f.count, ShouldEqual, 3000)
+			for i := 0; i < 3000; i++ {
+				So(f.contains(objHash([]byte(fmt.Sprintf("%d", i)))), ShouldBeTrue)
+			}
+		})
+
+		Convey("it should rule out most other hashes", func() {
+			positives := 0
+			for i := 3000; i < 103000; i++ {
+				if f.contains(objHash([]byte(fmt.Sprintf("%d", i)))) {
+					positives++
+				}
+			}
+			So(float64(positives)/100000, ShouldBeLessThan, 3*f.stat(1).FalsePositiveRate)
+		})
+
+		Convey("removed hashes should not be contained anymore", func() {
+			for i := 0; i < 1500; i++ {
+				f.remove(objHash([]byte(fmt.Sprintf("%d", i))))
+			}
+			So(f.count, ShouldEqual, 1500)
+			present := 0
+			for i := 0; i < 1500; i++ {
+				if f.contains(objHash([]byte(fmt.Sprintf("%d", i)))) {
+					present++
+				}
+			}
+			So(present, ShouldBeLessThan, 5)
+			for i := 1500; i < 3000; i++ {
+				So(f.contains(objHash([]byte(fmt.Sprintf("%d", i)))), ShouldBeTrue)
+			}
+		})
+	})
+
+	Convey("When inserting more equal hashes than fit into their buckets", t, func() {
+		f := newCuckooFilter(cuckooMinBuckets)
+		h := objHash([]byte("equal"))
+		for i := 0; i < 20; i++ {
+			f.insert(h)
+		}
+		So(len(f.stash), ShouldBeGreaterThan, 0)
+
+		Convey("the hash should remain contained until all of them are removed", func() {
+			for i := 0; i < 20; i++ {
+				So(f.contains(h), ShouldBeTrue)
+				f.remove(h)
+			}
+			So(f.contains(h), ShouldBeFalse)
+			So(f.count, ShouldEqual, 0)
+			So(f.stash, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSearchingWithCuckooFilter(t *testing.T) {
+	Convey("When adding objects to a store with a cuckoo filter", t, func() {
+		c := NewConfig()
+		c.CuckooFilter = true
+		o := NewObjectStore(c)
+		addrs := make([]ObjAddr, 5000)
+		for i := range addrs {
+			var err error
+			addrs[i], err = o.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+		}
+		So(o.Verify(), ShouldBeNil)
+
+		Convey("all of them should be found", func() {
+			for i := range addrs {
+				addr, found := o.Search([]byte(fmt.Sprintf("%05d", i)))
+				So(found, ShouldBeTrue)
+				So(addr, ShouldEqual, addrs[i])
+			}
+		})
+
+		Convey("most searches for absent objects should be answered by the filter", func() {
+			for i := 5000; i < 10000; i++ {
+				_, found := o.Search([]byte(fmt.Sprintf("%05d", i)))
+				So(found, ShouldBeFalse)
+			}
+			stat, err := o.FilterStatsByObjSize(5)
+			So(err, ShouldBeNil)
+			So(stat.Items, ShouldEqual, 5000)
+			So(stat.Negatives+stat.FalsePositives, ShouldEqual, 5000)
+			So(stat.FalsePositives, ShouldBeLessThan, 50)
+			So(stat.Bytes, ShouldBeGreaterThan, 0)
+			So(o.FilterStatsPerPool(), ShouldHaveLength, 1)
+		})
+
+		Convey("deleted objects should be ruled out by the filter", func() {
+			for i := 0; i < 2500; i++ {
+				So(o.Delete(addrs[i]), ShouldBeNil)
+			}
+			So(o.Verify(), ShouldBeNil)
+			for i := 0; i < 2500; i++ {
+				_, found := o.Search([]byte(fmt.Sprintf("%05d", i)))
+				So(found, ShouldBeFalse)
+			}
+			stat, _ := o.FilterStatsByObjSize(5)
+			So(stat.Items, ShouldEqual, 2500)
+			So(stat.Negatives, ShouldBeGreaterThan, 2450)
+		})
+
+		Convey("deleting an object twice should not remove the fingerprint of an equal live object", func() {
+			dup, err := o.Add([]byte("00042"))
+			So(err, ShouldBeNil)
+			So(o.Delete(addrs[42]), ShouldBeNil)
+			So(o.Delete(addrs[42]), ShouldNotBeNil)
+			_, err = o.Replace(addrs[42], []byte("abcde"))
+			So(err, ShouldNotBeNil)
+
+			addr, found := o.Search([]byte("00042"))
+			So(found, ShouldBeTrue)
+			So(addr, ShouldEqual, dup)
+			stat, _ := o.FilterStatsByObjSize(5)
+			So(stat.Items, ShouldEqual, 5000)
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("batched searches should find the present objects", func() {
+			results := o.slabPools[5].searchBatched([][]byte{[]byte("00100"), []byte("abcde"), []byte("04999")})
+			So(results[0], ShouldEqual, addrs[100])
+			So(results[1], ShouldEqual, 0)
+			So(results[2], ShouldEqual, addrs[4999])
+		})
+	})
+
+	Convey("When the cuckoo filter is disabled", t, func() {
+		o := NewObjectStore(NewConfig())
+		o.Add([]byte("abc"))
+		_, err := o.FilterStatsByObjSize(3)
+		So(err, ShouldNotBeNil)
+		So(o.FilterStatsPerPool(), ShouldBeEmpty)
+	})
+}
+
+func BenchmarkSearchingAbsentValueWithCuckooFilter(b *testing.B) {
+	benchmarkSearchingAbsentValue(b, true)
+}
+
+func BenchmarkSearchingAbsentValue(b *testing.B) {
+	benchmarkSearchingAbsentValue(b, false)
+}
+
+func benchmarkSearchingAbsentValue(b *testing.B, cuckooFilter bool) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 100
+	c.CuckooFilter = cuckooFilter
+	o := NewObjectStore(c)
+	for i := 0; i < 100000; i++ {
+		o.Add([]byte(fmt.Sprintf("%08d", i)))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, found := o.Search([]byte(fmt.Sprintf("%08d", 100000+n%100000))); found {
+			b.Errorf("Value %d has been found, but should not have", n)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package gos
+
+import (
+	"bytes"
+	"context"
+	"iter"
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Find returns an iterator over the addresses and values of all stored
+// objects for which pred returns true. If limit is greater than 0 the
+// iterator stops after yielding limit objects.
+// The slabs get scanned in parallel, so pred must be safe for concurrent
+// use, it may search the object store itself. The order in which objects
+// are yielded is undefined.
+// The object store must not be modified during the iteration, and the
+// yielded values refer to the slab memory just like the ones returned by Get
+func (o *ObjectStore) Find(pred func([]byte) bool, limit int) iter.Seq2[ObjAddr, []byte] {
+	return o.FindSizes(1, 255, pred, limit)
+}
+
+// FindPrefix returns an iterator over the addresses and values of all
+// stored objects which start with the given prefix, see Find
+func (o *ObjectStore) FindPrefix(prefix []byte) iter.Seq2[ObjAddr, []byte] {
+	minSize := len(prefix)
+	if minSize == 0 {
+		minSize = 1
+	}
+	if minSize > 255 {
+		return func(yield func(ObjAddr, []byte) bool) {}
+	}
+	return o.FindSizes(uint8(minSize), 255, func(obj []byte) bool {
+		return bytes.HasPrefix(obj, prefix)
+	}, 0)
+}
+
+// FindSizes is like Find, but it only looks at the pools with object
+// sizes from minSize to maxSize (inclusive). In the size class mode the
+// object size of a pool is its size class
+func (o *ObjectStore) FindSizes(minSize, maxSize uint8, pred func([]byte) bool, limit int) iter.Seq2[ObjAddr, []byte] {
+	return func(yield func(ObjAddr, []byte) bool) {
+		var sizes []int
+		for size := range o.slabPools {
+			if size >= minSize && size <= maxSize {
+				sizes = append(sizes, int(size))
+			}
+		}
+		sort.Ints(sizes)
+
+		var slabs []*slab
+		for _, size := range sizes {
+			slabs = append(slabs, o.slabPools[uint8(size)].slabs...)
+		}
+
+		// the slabs get scanned in batches, the matches of each batch get
+		// yielded once its scan has completed. This way no scan is running
+		// while the caller's loop body runs, so it may use the scan
+		// workers itself, for example by calling Search
+		var found int64
+		limitReached := func() bool {
+			return limit > 0 && atomic.LoadInt64(&found) >= int64(limit)
+		}
+
+		for len(slabs) > 0 && !limitReached() {
+			var batch []*slab
+			var slots uint
+			for len(slabs) > 0 && slots < parallelScanThreshold*4 {
+				slots += slabs[0].objCount()
+				batch = append(batch, slabs[0])
+				slabs = slabs[1:]
+			}
+
+			var matches []findMatch
+			var mu sync.Mutex
+			scanSlabs(context.Background(), batch, func(currentSlab *slab) bool {
+				var slabMatches []findMatch
+
+			SLOTS:
+				for i, word := range currentSlab.bitmap() {
+					for word != 0 {
+						idx := uint(i)*64 + uint(bits.TrailingZeros64(word))
+						word &= word - 1
+
+						obj := currentSlab.getObjByIdx(idx)
+						if !pred(obj) {
+							continue
+						}
+						if n := atomic.AddInt64(&found, 1); limit > 0 && n > int64(limit) {
+							break SLOTS
+						}
+						slabMatches = append(slabMatches, findMatch{addr: objAddrFromObj(obj), value: obj})
+					}
+				}
+
+				mu.Lock()
+				matches = append(matches, slabMatches...)
+				mu.Unlock()
+
+				return !limitReached()
+			})
+
+			for _, match := range matches {
+				if !yield(match.addr, match.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// findMatch is an object which has been found by FindSizes
+type findMatch struct {
+	addr  ObjAddr
+	value []byte
+}
@@ -0,0 +1,97 @@
+package gos
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFind(t *testing.T) {
+	Convey("When finding objects in a store with multiple pools", t, func() {
+		o := NewObjectStore(NewConfig())
+		added := make(map[ObjAddr]string)
+		for i := 0; i < 2000; i++ {
+			for _, format := range []string{"key%d", "key-%06d", "other%d"} {
+				value := fmt.Sprintf(format, i)
+				addr, err := o.Add([]byte(value))
+				So(err, ShouldBeNil)
+				added[addr] = value
+			}
+		}
+
+		Convey("FindPrefix should yield every object with the prefix", func() {
+			found := make(map[ObjAddr]string)
+			for addr, value := range o.FindPrefix([]byte("key-")) {
+				So(bytes.HasPrefix(value, []byte("key-")), ShouldBeTrue)
+				So(added[addr], ShouldEqual, string(value))
+				found[addr] = string(value)
+			}
+			So(len(found), ShouldEqual, 2000)
+		})
+
+		Convey("Find should stop after the limit", func() {
+			count := 0
+			for _, value := range o.Find(func(obj []byte) bool { return obj[0] == 'k' }, 10) {
+				So(value[0], ShouldEqual, 'k')
+				count++
+			}
+			So(count, ShouldEqual, 10)
+		})
+
+		Convey("the iteration should stop when the loop breaks", func() {
+			count := 0
+			for range o.Find(func([]byte) bool { return true }, 0) {
+				count++
+				if count == 5 {
+					break
+				}
+			}
+			So(count, ShouldEqual, 5)
+		})
+
+		Convey("FindSizes should only look at the given sizes", func() {
+			count := 0
+			for _, value := range o.FindSizes(4, 6, func([]byte) bool { return true }, 0) {
+				So(len(value), ShouldBeBetweenOrEqual, 4, 6)
+				count++
+			}
+			// key0 - key999 and other0 - other9 have lengths 4 to 6
+			So(count, ShouldEqual, 1010)
+		})
+
+		Convey("the loop body should be able to search the store", func() {
+			for addr, value := range o.FindPrefix([]byte("other1")) {
+				found, ok := o.Search(value)
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, addr)
+			}
+		})
+	})
+
+	Convey("When the predicate of Find searches a large pool", t, func() {
+		c := NewConfig()
+		c.BaseObjectsPerSlab = 255
+		c.GrowthFactor = 1
+		o := NewObjectStore(c)
+		for i := 0; i < parallelScanThreshold*2; i++ {
+			_, err := o.Add([]byte(fmt.Sprintf("%08d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the nested searches should complete", func() {
+			var count int
+			for range o.Find(func(obj []byte) bool {
+				if !bytes.HasSuffix(obj, []byte("0000")) {
+					return false
+				}
+				_, found := o.Search(obj)
+				return found
+			}, 0) {
+				count++
+			}
+			So(count, ShouldEqual, parallelScanThreshold*2/10000+1)
+		})
+	})
+}
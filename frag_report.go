@@ -0,0 +1,153 @@
+package gos
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// fillHistogramBuckets is the number of buckets in FragReport.FillHistogram
+const fillHistogramBuckets = 11
+
+// FragReport stores byte-weighted fragmentation insights about a slab pool.
+// When it describes the whole object store ObjSize is 0
+type FragReport struct {
+	ObjSize uint8
+	Slabs   int
+
+	// MappedBytes is the number of bytes mmapped by the slabs,
+	// including their headers and bitmaps
+	MappedBytes uint64
+
+	// LiveBytes is the number of bytes used by stored objects. In the pools
+	// of size classes it is the sum of the object lengths, so the unused
+	// rest of each object slot counts as wasted
+	LiveBytes uint64
+
+	// WastedBytes is the number of mapped bytes which aren't used by
+	// stored objects
+	WastedBytes uint64
+
+	// FragRatio is WastedBytes / MappedBytes, so 0 means that every mapped
+	// byte holds object data and 1 means that nothing is stored
+	FragRatio float64
+
+	// ReclaimableBytes is the number of bytes which could be unmapped if
+	// the stored objects were packed into as few slabs as possible. If it
+	// is close to 0 then compacting the pool won't reclaim memory
+	ReclaimableBytes uint64
+
+	// FillHistogram counts the slabs by how full they are. Bucket i counts
+	// the slabs with a fill ratio in [i*10%, (i+1)*10%), the last bucket
+	// counts the completely full slabs
+	FillHistogram [fillHistogramBuckets]int
+}
+
+// add adds the values of another report to this one and
+// updates the fragmentation ratio accordingly
+func (r *FragReport) add(other FragReport) {
+	r.Slabs += other.Slabs
+	r.MappedBytes += other.MappedBytes
+	r.LiveBytes += other.LiveBytes
+	r.WastedBytes += other.WastedBytes
+	r.ReclaimableBytes += other.ReclaimableBytes
+	for i := range r.FillHistogram {
+		r.FillHistogram[i] += other.FillHistogram[i]
+	}
+	r.FragRatio = fragRatio(r.WastedBytes, r.MappedBytes)
+}
+
+// fragRatio returns the ratio of wasted bytes to mapped bytes
+func fragRatio(wasted, mapped uint64) float64 {
+	if mapped == 0 {
+		return 0
+	}
+	return float64(wasted) / float64(mapped)
+}
+
+// liveBytes returns the number of bytes of the objects stored in this slab.
+// In the slabs of a size class the objects can be shorter than their slots,
+// so the stored lengths get summed up
+func (s *slab) liveBytes() uint64 {
+	if s.flags&slabFlagLengthPrefix == 0 {
+		return uint64(s.live) * uint64(s.objSize)
+	}
+	var total uint64
+	for i, word := range s.bitmap() {
+		for word != 0 {
+			idx := uint(i)*64 + uint(bits.TrailingZeros64(word))
+			word &= word - 1
+			total += uint64(s.objLen(s.addr() + s.getObjOffset(idx)))
+		}
+	}
+	return total
+}
+
+// fragReport creates a FragReport describing this slab pool
+func (s *slabPool) fragReport() FragReport {
+	r := FragReport{
+		ObjSize:     s.objSize,
+		Slabs:       len(s.slabs),
+		MappedBytes: s.mapped,
+	}
+	for _, sl := range s.slabs {
+		r.LiveBytes += sl.liveBytes()
+	}
+	r.WastedBytes = r.MappedBytes - r.LiveBytes
+	r.FragRatio = fragRatio(r.WastedBytes, r.MappedBytes)
+
+	for _, sl := range s.slabs {
+		bucket := fillHistogramBuckets - 1
+		if !sl.full() {
+			bucket = int(uint64(sl.live) * (fillHistogramBuckets - 1) / uint64(sl.objCount()))
+		}
+		r.FillHistogram[bucket]++
+	}
+
+	// if the objects were packed into the largest slabs, then all other
+	// slabs could be unmapped
+	bySize := make([]*slab, len(s.slabs))
+	copy(bySize, s.slabs)
+	sort.Slice(bySize, func(i, j int) bool { return bySize[i].objCount() > bySize[j].objCount() })
+	var needed, kept uint64
+	for _, sl := range bySize {
+		if needed >= s.objects {
+			break
+		}
+		needed += uint64(sl.objCount())
+		kept += uint64(sl.getTotalLength())
+	}
+	r.ReclaimableBytes = r.MappedBytes - kept
+
+	return r
+}
+
+// FragReportByObjSize returns the fragmentation report of
+// the requested pool as specified by size
+func (o *ObjectStore) FragReportByObjSize(size uint8) (FragReport, error) {
+	pool, ok := o.slabPools[size]
+	if !ok {
+		return FragReport{}, fmt.Errorf("ObjectStore: FragReportByObjSize failed to find pool with object size %d", size)
+	}
+
+	return pool.fragReport(), nil
+}
+
+// FragReportPerPool returns a slice containing a FragReport for each
+// non-empty slab pool
+func (o *ObjectStore) FragReportPerPool() (reports []FragReport) {
+	for _, p := range o.slabPools {
+		reports = append(reports, p.fragReport())
+	}
+	return
+}
+
+// FragReportTotal returns a fragmentation report across the whole
+// object store, all values are weighted by the size of the pools
+func (o *ObjectStore) FragReportTotal() FragReport {
+	var total FragReport
+	for _, p := range o.slabPools {
+		total.add(p.fragReport())
+	}
+	return total
+}
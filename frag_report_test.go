@@ -0,0 +1,84 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFragReport(t *testing.T) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 10
+	c.GrowthFactor = 1
+
+	// each slab has a 16 byte header, one bitmap word and 10 slots of 5 bytes
+	slabLen := uint64(16 + 8 + 10*5)
+
+	Convey("When filling 3 slabs and then deleting half of the objects in two of them", t, func() {
+		os := NewObjectStore(c)
+		var addrs []ObjAddr
+		for i := 0; i < 30; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		for i := 0; i < 5; i++ {
+			So(os.Delete(addrs[i]), ShouldBeNil)
+			So(os.Delete(addrs[i+10]), ShouldBeNil)
+		}
+
+		Convey("the report should weight the slabs by their size", func() {
+			report, err := os.FragReportByObjSize(5)
+			So(err, ShouldBeNil)
+			So(report.Slabs, ShouldEqual, 3)
+			So(report.MappedBytes, ShouldEqual, 3*slabLen)
+			So(report.LiveBytes, ShouldEqual, 20*5)
+			So(report.WastedBytes, ShouldEqual, 3*slabLen-100)
+			So(report.FragRatio, ShouldAlmostEqual, float64(3*slabLen-100)/float64(3*slabLen))
+			So(report.FillHistogram[5], ShouldEqual, 2)
+			So(report.FillHistogram[10], ShouldEqual, 1)
+
+			// the 20 remaining objects would fit into 2 slabs
+			So(report.ReclaimableBytes, ShouldEqual, slabLen)
+
+			_, err = os.FragReportByObjSize(6)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("the total should add up the reports of all pools", func() {
+			_, err := os.Add([]byte("abc"))
+			So(err, ShouldBeNil)
+
+			total := os.FragReportTotal()
+			So(len(os.FragReportPerPool()), ShouldEqual, 2)
+			So(total.Slabs, ShouldEqual, 4)
+			So(total.LiveBytes, ShouldEqual, 20*5+3)
+			So(total.FillHistogram[1], ShouldEqual, 1)
+
+			memTotal, err := os.MemStatsTotal()
+			So(err, ShouldBeNil)
+			So(total.MappedBytes, ShouldEqual, memTotal)
+		})
+	})
+}
+
+func TestFragReportWithGrowingSlabs(t *testing.T) {
+	Convey("When adding objects to a pool with growing slab sizes", t, func() {
+		pool := NewSlabPool(10)
+		for i := 0; i < 7; i++ {
+			_, _, err := pool.add([]byte(fmt.Sprintf("%10d", i)), 1, 2)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the mapped bytes should be the sum of all slab sizes", func() {
+			var mapped uint64
+			for _, sl := range pool.slabs {
+				mapped += uint64(sl.getTotalLength())
+			}
+			So(pool.memStats(), ShouldEqual, mapped)
+			So(pool.fragReport().MappedBytes, ShouldEqual, mapped)
+			So(pool.fragReport().ReclaimableBytes, ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package gos
+
+import "fmt"
+
+// Freeze mprotects the slabs of all pools read-only, so that writing into a
+// slice that has been returned by Get faults instead of silently corrupting
+// the stored object. Add and Delete keep working, they briefly make the
+// slab they modify writable. Slabs and pools which get created while the
+// store is frozen are read-only as well
+func (o *ObjectStore) Freeze() error {
+	for _, pool := range o.slabPools {
+		if err := pool.freeze(); err != nil {
+			return err
+		}
+	}
+	o.frozen = true
+	return nil
+}
+
+// Unfreeze makes the slabs of all pools writable again
+func (o *ObjectStore) Unfreeze() error {
+	for _, pool := range o.slabPools {
+		if err := pool.unfreeze(); err != nil {
+			return err
+		}
+	}
+	o.frozen = false
+	return nil
+}
+
+// FreezePool mprotects the slabs of the pool with the given object
+// size read-only, see Freeze
+func (o *ObjectStore) FreezePool(size uint8) error {
+	pool, ok := o.slabPools[size]
+	if !ok {
+		return fmt.Errorf("ObjectStore: FreezePool failed to find pool with object size %d", size)
+	}
+	return pool.freeze()
+}
+
+// UnfreezePool makes the slabs of the pool with the given object size
+// writable again
+func (o *ObjectStore) UnfreezePool(size uint8) error {
+	pool, ok := o.slabPools[size]
+	if !ok {
+		return fmt.Errorf("ObjectStore: UnfreezePool failed to find pool with object size %d", size)
+	}
+	return pool.unfreeze()
+}
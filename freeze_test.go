@@ -0,0 +1,78 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFreeze(t *testing.T) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 10
+
+	Convey("When freezing an object store after a bulk load", t, func() {
+		os := NewObjectStore(c)
+		var addrs []ObjAddr
+		for i := 0; i < 50; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%04d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		So(os.Freeze(), ShouldBeNil)
+		obj, err := os.Get(addrs[0])
+		So(err, ShouldBeNil)
+
+		Convey("reading objects should still work", func() {
+			So(string(obj), ShouldEqual, "0000")
+			_, found := os.Search([]byte("0042"))
+			So(found, ShouldBeTrue)
+		})
+
+		Convey("writing into an object should fault", func() {
+			So(faults(func() { obj[0] = 'x' }), ShouldBeTrue)
+			So(string(obj), ShouldEqual, "0000")
+		})
+
+		Convey("adding and deleting objects should still work", func() {
+			for _, objAddr := range addrs[1:40] {
+				So(os.Delete(objAddr), ShouldBeNil)
+			}
+			objAddr, err := os.Add([]byte("abcd"))
+			So(err, ShouldBeNil)
+			newPoolAddr, err := os.Add([]byte("abc"))
+			So(err, ShouldBeNil)
+			So(os.Verify(), ShouldBeNil)
+
+			newObj, _ := os.Get(objAddr)
+			So(faults(func() { newObj[0] = 'x' }), ShouldBeTrue)
+			newPoolObj, _ := os.Get(newPoolAddr)
+			So(faults(func() { newPoolObj[0] = 'x' }), ShouldBeTrue)
+			So(faults(func() { obj[0] = 'x' }), ShouldBeTrue)
+		})
+
+		Convey("after unfreezing the objects should be writable again", func() {
+			So(os.Unfreeze(), ShouldBeNil)
+			So(faults(func() { obj[0] = 'x' }), ShouldBeFalse)
+			So(string(obj), ShouldEqual, "x000")
+		})
+	})
+
+	Convey("When freezing a single pool", t, func() {
+		os := NewObjectStore(c)
+		addr1, _ := os.Add([]byte("abc"))
+		addr2, _ := os.Add([]byte("abcd"))
+		So(os.FreezePool(3), ShouldBeNil)
+		So(os.FreezePool(5), ShouldNotBeNil)
+		obj1, _ := os.Get(addr1)
+		obj2, _ := os.Get(addr2)
+
+		Convey("only its objects should be read-only", func() {
+			So(faults(func() { obj1[0] = 'x' }), ShouldBeTrue)
+			So(faults(func() { obj2[0] = 'x' }), ShouldBeFalse)
+
+			So(os.UnfreezePool(3), ShouldBeNil)
+			So(faults(func() { obj1[0] = 'x' }), ShouldBeFalse)
+		})
+	})
+}
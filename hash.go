@@ -0,0 +1,49 @@
+package gos
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// hashSeed is the seed of all object hashes, object hashes are never
+// persisted so it is fine that it changes with every process
+var hashSeed = maphash.MakeSeed()
+
+// objHash returns the 64 bit hash of the given object
+func objHash(obj []byte) uint64 {
+	return maphash.Bytes(hashSeed, obj)
+}
+
+// fingerprintOf returns the one byte fingerprint of the given object
+// which gets stored in slabs with fingerprints
+func fingerprintOf(obj []byte) byte {
+	return byte(objHash(obj) >> 56)
+}
+
+// fingerprintWordsFor returns the number of words (uint64) which are
+// needed to store one fingerprint byte for each of objCount objects
+func fingerprintWordsFor(objCount uint) int {
+	return int((uint64(objCount) + 7) / 8)
+}
+
+const (
+	lowBits7  = 0x7f7f7f7f7f7f7f7f
+	highBits  = 0x8080808080808080
+	lowBytes1 = 0x0101010101010101
+)
+
+// matchBytes compares each of the 8 bytes in word to b, it returns a word
+// in which the highest bit of each byte is set if that byte equals b and
+// all other bits are 0
+func matchBytes(word uint64, b byte) uint64 {
+	x := word ^ (lowBytes1 * uint64(b))
+	// the high bit of each byte of x is set if the byte is 0, unlike the
+	// simpler (x - 0x01..) & ^x trick this has no false positives
+	return ^((x&lowBits7 + lowBits7) | x | lowBits7)
+}
+
+// nextMatch takes a result of matchBytes and returns the index of the
+// first matching byte and the remaining matches
+func nextMatch(matches uint64) (uint, uint64) {
+	return uint(bits.TrailingZeros64(matches)) / 8, matches & (matches - 1)
+}
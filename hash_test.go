@@ -0,0 +1,61 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchBytes(t *testing.T) {
+	Convey("When matching bytes in a word", t, func() {
+		word := uint64(0x0001ff0100ab0100)
+
+		Convey("only the equal bytes should be marked", func() {
+			So(matchBytes(word, 0x01), ShouldEqual, uint64(0x0080008000008000))
+			So(matchBytes(word, 0x00), ShouldEqual, uint64(0x8000000080000080))
+			So(matchBytes(word, 0xff), ShouldEqual, uint64(0x0000800000000000))
+			So(matchBytes(word, 0x02), ShouldEqual, 0)
+		})
+
+		Convey("nextMatch should iterate over the matching byte indexes", func() {
+			var indexes []uint
+			matches := matchBytes(word, 0x01)
+			for matches != 0 {
+				var idx uint
+				idx, matches = nextMatch(matches)
+				indexes = append(indexes, idx)
+			}
+			So(indexes, ShouldResemble, []uint{1, 4, 6})
+		})
+	})
+}
+
+func TestFingerprintSlab(t *testing.T) {
+	Convey("When creating a slab with fingerprints", t, func() {
+		slab, err := newSlabWithFlags(4, 20, slabFlagFingerprints)
+		So(err, ShouldBeNil)
+		So(slab.getDataOffset(), ShouldEqual, sizeOfSlabHeader+8+24)
+
+		for i := uint(0); i < 20; i++ {
+			_, _, success := slab.addObj([]byte{'a', 'b', 'c', byte(i)}, i)
+			So(success, ShouldBeTrue)
+		}
+
+		Convey("the fingerprints should be stored and used to find objects", func() {
+			So(slab.fingerprintBytes()[7], ShouldEqual, fingerprintOf([]byte{'a', 'b', 'c', 7}))
+			addr, found := slab.findObj([]byte{'a', 'b', 'c', 19}, fingerprintOf([]byte{'a', 'b', 'c', 19}))
+			So(found, ShouldBeTrue)
+			So(addr, ShouldEqual, slab.addr()+slab.getObjOffset(19))
+
+			_, found = slab.findObj([]byte("abcd"), fingerprintOf([]byte("abcd")))
+			So(found, ShouldBeFalse)
+			So(slab.verify(), ShouldBeEmpty)
+		})
+
+		Convey("deleted objects should not be found", func() {
+			slab.delete(slab.addr() + slab.getObjOffset(3))
+			_, found := slab.findObj([]byte{'a', 'b', 'c', 3}, fingerprintOf([]byte{'a', 'b', 'c', 3}))
+			So(found, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package gos
+
+import "sort"
+
+// hugePageSize is the size of the transparent huge pages of the kernel on
+// amd64 and arm64 with 4KB pages
+const hugePageSize = 2 << 20
+
+// roundUpToHugePage rounds the given length up to a multiple of the huge
+// page size
+func roundUpToHugePage(length uintptr) uintptr {
+	return (length + hugePageSize - 1) &^ (hugePageSize - 1)
+}
+
+// wantsHugePages returns true if a slab of the given length should be
+// backed by huge pages. Smaller slabs would waste most of a huge page, or
+// get many more objects than their pool needs once they get packed
+func wantsHugePages(length uintptr) bool {
+	return length >= hugePageSize/2
+}
+
+// packHugePages raises the given object count of a slab, which should be
+// backed by huge pages, so that its object slots fill the huge pages
+// It returns the given object count if the slab is too small for huge pages
+func packHugePages(objCount uint, objSize uint8, flags uint16) uint {
+	length := slabLenFor(objSize, objCount, flags)
+	if !wantsHugePages(length) {
+		return objCount
+	}
+	target := uint64(roundUpToHugePage(length))
+
+	// every additional object needs at least a slot, so the packed count
+	// is at most this high
+	maxCount := objCount + uint((uintptr(target)-length)/slotSizeFor(objSize, flags))
+	if maxCount > maxObjCountPerSlab {
+		maxCount = maxObjCountPerSlab
+	}
+	if maxCount <= objCount {
+		return objCount
+	}
+	extra := sort.Search(int(maxCount-objCount), func(n int) bool {
+		return uint64(slabLenFor(objSize, objCount+uint(n)+1, flags)) > target
+	})
+	return objCount + uint(extra)
+}
@@ -0,0 +1,55 @@
+package gos
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// transparentHugePages is true on the platforms which support transparent
+// huge pages, on other platforms HugePages gets ignored
+const transparentHugePages = true
+
+// mapHugePageMemory mmaps the memory for a slab of the given length at an
+// address which is aligned to the huge page size and advises the kernel to
+// back it with transparent huge pages
+// If transparent huge pages are disabled the madvise call fails, then the
+// slab is backed by normal pages
+// The mapping is created with raw syscalls, because syscall.Munmap can only
+// unmap whole mappings which have been created by syscall.Mmap, while the
+// unaligned head and tail of this mapping get unmapped right away
+func mapHugePageMemory(totalLen uintptr) (SlabAddr, error) {
+	length := roundUpToHugePage(totalLen)
+	start, _, errno := syscall.Syscall6(syscall.SYS_MMAP, 0, length+hugePageSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE, ^uintptr(0), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+
+	aligned := roundUpToHugePage(start)
+	if aligned > start {
+		if err := munmapRaw(start, aligned-start); err != nil {
+			munmapRaw(start, length+hugePageSize)
+			return 0, err
+		}
+	}
+	if end := start + length + hugePageSize; end > aligned+length {
+		if err := munmapRaw(aligned+length, end-aligned-length); err != nil {
+			munmapRaw(aligned, end-aligned)
+			return 0, err
+		}
+	}
+
+	// the error is ignored, if transparent huge pages are disabled or not
+	// supported the slab simply uses normal pages
+	syscall.Madvise(unsafe.Slice((*byte)(unsafe.Pointer(aligned)), length), syscall.MADV_HUGEPAGE)
+
+	return SlabAddr(aligned), nil
+}
+
+// munmapRaw unmaps the given memory area, unlike syscall.Munmap it doesn't
+// require the area to be a mapping which has been created by syscall.Mmap
+func munmapRaw(addr, length uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, addr, length, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHugePages(t *testing.T) {
+	Convey("HugePages can't be used in debug mode", t, func() {
+		_, err := New(WithHugePages(), WithDebug(0))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("When adding many objects to a store with huge pages", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(255), WithGrowthFactor(2), WithHugePages(), WithFingerprints())
+		So(err, ShouldBeNil)
+
+		var addrs []ObjAddr
+		for i := 0; i < 50000; i++ {
+			objAddr, err := o.Add([]byte(fmt.Sprintf("%100d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		pool := o.slabPools[100]
+
+		Convey("the large slabs should be aligned and packed", func() {
+			var huge int
+			for _, s := range pool.slabs {
+				length := s.getTotalLength()
+				if s.flags&slabFlagHugePages == 0 {
+					So(wantsHugePages(length), ShouldBeFalse)
+					continue
+				}
+				huge++
+				So(uintptr(s.addr())%hugePageSize, ShouldEqual, 0)
+				So(length+s.slotSize(), ShouldBeGreaterThan, roundUpToHugePage(length))
+			}
+			So(huge, ShouldBeGreaterThan, 0)
+			So(huge, ShouldBeLessThan, len(pool.slabs))
+
+			sizes := o.Config().SlabSizes(100, len(pool.slabs))
+			var total uintptr
+			for _, size := range sizes {
+				total += size.Bytes
+			}
+			So(uint64(total), ShouldEqual, pool.mapped)
+		})
+
+		Convey("releasing memory shouldn't split the huge pages", func() {
+			for i, objAddr := range addrs {
+				if i%1000 != 0 {
+					So(o.Delete(objAddr), ShouldBeNil)
+				}
+			}
+			released, err := o.ReleaseMemory()
+			So(err, ShouldBeNil)
+			So(released, ShouldBeGreaterThan, 0)
+			for _, s := range pool.slabs {
+				if s.flags&slabFlagHugePages != 0 {
+					released, err := s.releaseAll()
+					So(err, ShouldBeNil)
+					So(released, ShouldEqual, 0)
+				}
+			}
+		})
+
+		Convey("the objects should be accessible and deletable", func() {
+			for i, objAddr := range addrs {
+				obj, err := o.Get(objAddr)
+				So(err, ShouldBeNil)
+				So(string(obj), ShouldEqual, fmt.Sprintf("%100d", i))
+			}
+			So(o.Verify(), ShouldBeNil)
+
+			for _, objAddr := range addrs {
+				So(o.Delete(objAddr), ShouldBeNil)
+			}
+			So(o.Len(), ShouldEqual, 0)
+			So(len(o.lookupTable), ShouldEqual, 0)
+		})
+	})
+}
+
+// benchmarkRandomGet gets the objects of a store with 2M objects of 64
+// bytes in random order
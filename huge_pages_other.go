@@ -0,0 +1,21 @@
+//go:build !linux
+
+package gos
+
+import "fmt"
+
+// transparentHugePages is true on the platforms which support transparent
+// huge pages, on other platforms HugePages gets ignored
+const transparentHugePages = false
+
+// mapHugePageMemory is never called without transparent huge pages, because
+// no slab gets the slabFlagHugePages
+func mapHugePageMemory(totalLen uintptr) (SlabAddr, error) {
+	return 0, fmt.Errorf("mapHugePageMemory: huge pages are only supported on Linux")
+}
+
+// munmapRaw is never called without transparent huge pages, see
+// mapHugePageMemory
+func munmapRaw(addr, length uintptr) error {
+	return fmt.Errorf("munmapRaw: huge pages are only supported on Linux")
+}
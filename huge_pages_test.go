@@ -0,0 +1,65 @@
+package gos
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPackHugePages(t *testing.T) {
+	Convey("Small slabs shouldn't get packed", t, func() {
+		So(packHugePages(100, 100, 0), ShouldEqual, 100)
+	})
+
+	Convey("Large slabs should fill their huge pages", t, func() {
+		for _, flags := range []uint16{0, slabFlagFingerprints | slabFlagExpiry, slabFlagLengthPrefix | slabFlagClock} {
+			target := roundUpToHugePage(slabLenFor(100, 15000, flags))
+			objCount := packHugePages(15000, 100, flags)
+			So(objCount, ShouldBeGreaterThan, 15000)
+			So(slabLenFor(100, objCount, flags), ShouldBeLessThanOrEqualTo, target)
+			So(slabLenFor(100, objCount+1, flags), ShouldBeGreaterThan, target)
+		}
+	})
+}
+
+func benchmarkRandomGet(b *testing.B, hugePages bool) {
+	c := NewConfig()
+	c.GrowthFactor = 2
+	c.HugePages = hugePages
+	o, err := NewObjectStoreChecked(c)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	addrs := make([]ObjAddr, 2<<20)
+	for i := range addrs {
+		addrs[i], err = o.Add([]byte(fmt.Sprintf("%64d", i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+
+	b.ResetTimer()
+	var sum byte
+	for i := 0; i < b.N; i++ {
+		obj, err := o.Get(addrs[i%len(addrs)])
+		if err != nil {
+			b.Fatal(err)
+		}
+		sum += obj[63]
+	}
+	b.StopTimer()
+
+	for _, objAddr := range addrs {
+		if err = o.Delete(objAddr); err != nil {
+			b.Fatal(err)
+		}
+	}
+	_ = sum
+}
+
+func BenchmarkRandomGet(b *testing.B)          { benchmarkRandomGet(b, false) }
+func BenchmarkRandomGetHugePages(b *testing.B) { benchmarkRandomGet(b, true) }
@@ -0,0 +1,196 @@
+package gos
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"sort"
+	"syscall"
+	"unsafe"
+)
+
+// indexEntry refers to an object in the ordered index. The object size is
+// stored with the address, so comparing entries doesn't require a lookup
+// of the slab which contains the object
+type indexEntry struct {
+	addr ObjAddr
+	size uint8
+}
+
+// indexEntrySize is the number of bytes used by each entry of the index
+const indexEntrySize = unsafe.Sizeof(indexEntry{})
+
+// orderedIndex is a sorted array of the stored objects. The array is kept
+// in MMapped memory, like the slabs, so that it is ignored by the Go GC.
+// The entries are sorted by the object values, objects with equal values
+// are sorted by their addresses
+type orderedIndex struct {
+	mapping []byte
+	entries []indexEntry
+}
+
+// value returns the object which is referred to by the given entry
+func (e indexEntry) value() []byte {
+	return objFromObjAddr(e.addr, e.size)
+}
+
+// compare compares the given entry to the given object and address
+func (e indexEntry) compare(obj []byte, addr ObjAddr) int {
+	if cmp := bytes.Compare(e.value(), obj); cmp != 0 {
+		return cmp
+	}
+	if e.addr < addr {
+		return -1
+	}
+	if e.addr > addr {
+		return 1
+	}
+	return 0
+}
+
+// search returns the index of the first entry which is not smaller than
+// the given object and address
+func (x *orderedIndex) search(obj []byte, addr ObjAddr) int {
+	return sort.Search(len(x.entries), func(i int) bool { return x.entries[i].compare(obj, addr) >= 0 })
+}
+
+// find returns the position of the entry of the given object
+// The second return value is false if the object isn't in the index
+func (x *orderedIndex) find(addr ObjAddr, size uint8) (int, bool) {
+	idx := x.search(objFromObjAddr(addr, size), addr)
+	return idx, idx < len(x.entries) && x.entries[idx].addr == addr
+}
+
+// reserve makes sure that there is space for at least one more entry, so
+// that a following insert cannot fail
+func (x *orderedIndex) reserve() error {
+	if len(x.entries) < cap(x.entries) {
+		return nil
+	}
+	newCap := 2 * cap(x.entries)
+	if minCap := int(pageSize / indexEntrySize); newCap < minCap {
+		newCap = minCap
+	}
+	return x.remap(newCap)
+}
+
+// insert adds an entry for the given object at its sorted position
+// The space for it must have been reserved before
+func (x *orderedIndex) insert(addr ObjAddr, size uint8) {
+	idx := x.search(objFromObjAddr(addr, size), addr)
+	x.entries = x.entries[:len(x.entries)+1]
+	copy(x.entries[idx+1:], x.entries[idx:])
+	x.entries[idx] = indexEntry{addr: addr, size: size}
+}
+
+// update moves the entry at the given position to the sorted position of
+// its object, after the object has been overwritten with a value of the
+// given size. Unlike removeAt and insert it never remaps the index
+func (x *orderedIndex) update(idx int, size uint8) {
+	addr := x.entries[idx].addr
+	copy(x.entries[idx:], x.entries[idx+1:])
+	x.entries = x.entries[:len(x.entries)-1]
+	x.insert(addr, size)
+}
+
+// removeAt removes the entry at the given position
+// Once less than a quarter of the entries is in use the index gets shrunk
+func (x *orderedIndex) removeAt(idx int) {
+	copy(x.entries[idx:], x.entries[idx+1:])
+	x.entries = x.entries[:len(x.entries)-1]
+
+	if len(x.entries) == 0 {
+		// if unmapping fails we keep using the mapping
+		if syscall.Munmap(x.mapping) == nil {
+			x.mapping, x.entries = nil, nil
+		}
+		return
+	}
+	if len(x.entries) < cap(x.entries)/4 && uintptr(cap(x.entries)/2)*indexEntrySize >= pageSize {
+		// if shrinking fails we keep the current mapping
+		x.remap(cap(x.entries) / 2)
+	}
+}
+
+// remap moves the entries into a new mapping with the given capacity
+func (x *orderedIndex) remap(newCap int) error {
+	mapping, err := syscall.Mmap(-1, 0, int(roundUpToPage(uintptr(newCap)*indexEntrySize)), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return err
+	}
+	entries := unsafe.Slice((*indexEntry)(unsafe.Pointer(&mapping[0])), uintptr(len(mapping))/indexEntrySize)[:len(x.entries)]
+	copy(entries, x.entries)
+
+	if x.mapping != nil {
+		if err = syscall.Munmap(x.mapping); err != nil {
+			syscall.Munmap(mapping)
+			return err
+		}
+	}
+	x.mapping, x.entries = mapping, entries
+	return nil
+}
+
+// Range returns an iterator over the addresses and values of the stored
+// objects which are greater than or equal to start and less than end,
+// in lexicographic order of their values. A nil start or end leaves that
+// side of the range unbounded.
+// Range requires the OrderedIndex to be enabled in the ObjectStoreConfig,
+// otherwise it returns an error. The object store must not be modified
+// during the iteration
+func (o *ObjectStore) Range(start, end []byte) (iter.Seq2[ObjAddr, []byte], error) {
+	if o.index == nil {
+		return nil, fmt.Errorf("ObjectStore: Range requires the OrderedIndex to be enabled")
+	}
+	return func(yield func(ObjAddr, []byte) bool) {
+		idx := 0
+		if start != nil {
+			idx = o.index.search(start, 0)
+		}
+		for ; idx < len(o.index.entries); idx++ {
+			entry := o.index.entries[idx]
+			value := entry.value()
+			if end != nil && bytes.Compare(value, end) >= 0 {
+				return
+			}
+			if !yield(entry.addr, value) {
+				return
+			}
+		}
+	}, nil
+}
+
+// ForEach calls fn for every stored object in lexicographic order of
+// their values, until fn returns false.
+// Just like Range it returns an error if the OrderedIndex isn't enabled
+func (o *ObjectStore) ForEach(fn func(ObjAddr, []byte) bool) error {
+	objects, err := o.Range(nil, nil)
+	if err != nil {
+		return err
+	}
+	for addr, value := range objects {
+		if !fn(addr, value) {
+			break
+		}
+	}
+	return nil
+}
+
+// verify checks that the index is sorted and refers to exactly the live
+// objects of the given object store
+// It returns a description of each violation
+func (x *orderedIndex) verify(o *ObjectStore) (violations []string) {
+	if len(x.entries) != o.Len() {
+		violations = append(violations, fmt.Sprintf("has %d entries, but the store contains %d objects", len(x.entries), o.Len()))
+	}
+	for i, entry := range x.entries {
+		if i > 0 && x.entries[i-1].compare(entry.value(), entry.addr) >= 0 {
+			violations = append(violations, fmt.Sprintf("is not sorted at index %d", i))
+		}
+		sAddr, err := o.getSlabAddress(entry.addr)
+		if err != nil || checkObjAddr(entry.addr, sAddr) != nil || slabFromSlabAddr(sAddr).objLen(entry.addr) != entry.size {
+			violations = append(violations, fmt.Sprintf("entry %d doesn't refer to a live object", i))
+		}
+	}
+	return violations
+}
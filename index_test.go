@@ -0,0 +1,102 @@
+package gos
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOrderedIndex(t *testing.T) {
+	Convey("When adding objects of different sizes to a store with an ordered index", t, func() {
+		c := NewConfig()
+		c.OrderedIndex = true
+		o := NewObjectStore(c)
+
+		var values []string
+		addrs := make(map[string]ObjAddr)
+		for _, i := range rand.New(rand.NewSource(1)).Perm(3000) {
+			value := fmt.Sprintf("%x", i*7919)
+			addr, err := o.Add([]byte(value))
+			So(err, ShouldBeNil)
+			values = append(values, value)
+			addrs[value] = addr
+		}
+		sort.Strings(values)
+		So(o.Verify(), ShouldBeNil)
+
+		Convey("ForEach should visit all objects in order", func() {
+			var visited []string
+			err := o.ForEach(func(addr ObjAddr, value []byte) bool {
+				So(addr, ShouldEqual, addrs[string(value)])
+				visited = append(visited, string(value))
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(visited, ShouldResemble, values)
+		})
+
+		Convey("Range should only yield the objects inside of the range", func() {
+			var visited []string
+			objects, err := o.Range([]byte("2"), []byte("4"))
+			So(err, ShouldBeNil)
+			for _, value := range objects {
+				visited = append(visited, string(value))
+			}
+			from := sort.SearchStrings(values, "2")
+			to := sort.SearchStrings(values, "4")
+			So(visited, ShouldResemble, values[from:to])
+		})
+
+		Convey("deleted objects should disappear from the index", func() {
+			for _, value := range values[:2900] {
+				So(o.Delete(addrs[value]), ShouldBeNil)
+			}
+			So(o.Verify(), ShouldBeNil)
+
+			var visited []string
+			objects, err := o.Range(nil, nil)
+			So(err, ShouldBeNil)
+			for _, value := range objects {
+				visited = append(visited, string(value))
+			}
+			So(visited, ShouldResemble, values[2900:])
+
+			for _, value := range values[2900:] {
+				So(o.Delete(addrs[value]), ShouldBeNil)
+			}
+			So(o.index.mapping, ShouldBeNil)
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+
+	Convey("When adding equal objects", t, func() {
+		c := NewConfig()
+		c.OrderedIndex = true
+		o := NewObjectStore(c)
+		for i := 0; i < 10; i++ {
+			_, err := o.Add([]byte("same"))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("all of them should be in the index", func() {
+			count := 0
+			objects, err := o.Range([]byte("same"), []byte("samf"))
+			So(err, ShouldBeNil)
+			for range objects {
+				count++
+			}
+			So(count, ShouldEqual, 10)
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+
+	Convey("When ranging over a store without an ordered index", t, func() {
+		o := NewObjectStore(NewConfig())
+		_, err := o.Range(nil, nil)
+		So(err, ShouldNotBeNil)
+		So(o.ForEach(func(ObjAddr, []byte) bool { return true }), ShouldNotBeNil)
+	})
+}
@@ -1,9 +1,10 @@
 package gos
 
 import (
+	"context"
 	"fmt"
-	"reflect"
 	"sort"
+	"time"
 	"unsafe"
 )
 
@@ -11,19 +12,77 @@ import (
 var sizeOfSlabPool = 8 + unsafe.Sizeof(uintptr(0)) + unsafe.Sizeof([]*slab{})
 
 // MemStat stores memory usage statistics about a slab pool
+// MemUsed is the number of mmapped bytes, Resident is the number of those
+// bytes which are resident in RAM
 type MemStat struct {
-	ObjSize uint8
-	MemUsed uint64
+	ObjSize  uint8
+	MemUsed  uint64
+	Resident uint64
 }
 
 // FragStat stores fragmentation insights about a slab pool
+// Note that FragPercent is the average fill ratio of the pool's slabs,
+// see FragReport for byte-weighted fragmentation metrics
 type FragStat struct {
 	ObjSize     uint8
 	FragPercent float32
 }
 
+// CountStat stores object count statistics about a slab pool
+type CountStat struct {
+	ObjSize  uint8
+	Objects  uint64 // number of stored objects
+	Capacity uint64 // number of object slots in all slabs
+	Free     uint64 // number of unused object slots
+	Slabs    int
+}
+
+// Len returns the number of objects stored in the object store
+func (o *ObjectStore) Len() int {
+	var total uint64
+	for _, p := range o.slabPools {
+		total += p.objects
+	}
+	return int(total)
+}
+
+// LenByObjSize returns the number of objects stored in the pool
+// of the given object size. In the size class mode the pools are keyed by
+// their class, so size has to be a class and the result counts the objects
+// of all lengths which are stored in that class
+func (o *ObjectStore) LenByObjSize(size uint8) int {
+	if pool, ok := o.slabPools[size]; ok {
+		return int(pool.objects)
+	}
+	return 0
+}
+
+// CountStatsByObjSize returns the object count statistics of
+// the requested pool as specified by size. Just like for LenByObjSize
+// the pools are keyed by their class in the size class mode
+func (o *ObjectStore) CountStatsByObjSize(size uint8) (CountStat, error) {
+	pool, ok := o.slabPools[size]
+	if !ok {
+		return CountStat{}, fmt.Errorf("ObjectStore: CountStatsByObjSize failed to find pool with object size %d", size)
+	}
+
+	return pool.countStats(), nil
+}
+
+// CountStatsPerPool returns a slice containing a CountStat for each
+// non-empty slab pool, in the size class mode ObjSize is the class
+func (o *ObjectStore) CountStatsPerPool() (countStats []CountStat) {
+	for _, p := range o.slabPools {
+		countStats = append(countStats, p.countStats())
+	}
+	return
+}
+
 // FragStatsByObjSize returns the fragmentation percent of
 // the requested pool as specified by size
+//
+// Deprecated: the returned value is the unweighted average fill ratio
+// of the pool's slabs, use FragReportByObjSize instead
 func (o *ObjectStore) FragStatsByObjSize(size uint8) (float32, error) {
 	// check if pool exists
 	var pool *slabPool
@@ -37,6 +96,8 @@ func (o *ObjectStore) FragStatsByObjSize(size uint8) (float32, error) {
 
 // FragStatsPerPool returns a slice containing a FragStat for each
 // non-empty slab pool
+//
+// Deprecated: use FragReportPerPool instead
 func (o *ObjectStore) FragStatsPerPool() (fragStats []FragStat) {
 	for _, sl := range o.slabPools {
 		fragPercent := sl.fragStats()
@@ -46,6 +107,9 @@ func (o *ObjectStore) FragStatsPerPool() (fragStats []FragStat) {
 }
 
 // FragStatsTotal returns the total fragmentation percent across the object store
+//
+// Deprecated: the returned value averages all pools equally, use
+// FragReportTotal instead
 func (o *ObjectStore) FragStatsTotal() (float32, error) {
 	var total float32
 	var numPools float32
@@ -82,7 +146,12 @@ func (o *ObjectStore) MemStatsByObjSize(size uint8) (uint64, error) {
 func (o *ObjectStore) MemStatsPerPool() (memStats []MemStat) {
 	for _, p := range o.slabPools {
 		memUsed := p.memStats()
-		memStats = append(memStats, MemStat{ObjSize: p.objSize, MemUsed: memUsed})
+		resident, err := p.residentBytes()
+		if err != nil {
+			// the resident bytes are unknown if mincore fails
+			resident = 0
+		}
+		memStats = append(memStats, MemStat{ObjSize: p.objSize, MemUsed: memUsed, Resident: resident})
 	}
 	return
 }
@@ -105,15 +174,60 @@ type ObjectStore struct {
 	slabPools   map[uint8]*slabPool
 	lookupTable []SlabAddr
 	config      ObjectStoreConfig
+
+	// stats is nil unless config.CollectStats is enabled
+	stats *opStats
+
+	// quarantine is nil unless config.Debug is enabled
+	quarantine *quarantine
+
+	// frozen is true if all pools, including new ones, are frozen
+	frozen bool
+
+	// index is nil unless config.OrderedIndex is enabled
+	index *orderedIndex
+
+	// sizeClasses is nil unless config.SizeClasses is set
+	sizeClasses *sizeClassTable
+
+	// pinned is an object which must not get evicted in the cache mode,
+	// Replace pins the replaced object while it adds the new value
+	pinned ObjAddr
+}
+
+// NewObjectStoreChecked is like NewObjectStore, but it validates the given
+// configuration first and returns an error if it is invalid
+func NewObjectStoreChecked(c ObjectStoreConfig) (ObjectStore, error) {
+	if err := c.Validate(); err != nil {
+		return ObjectStore{}, err
+	}
+	return NewObjectStore(c), nil
 }
 
 // NewObjectStore initializes a new object store with the given configuration
-// Once an object store has been initialized its configuration cannot be changed
+// Once an object store has been initialized only its growth settings and
+// memory limit can be changed, see SetGrowth and SetMaxBytes
+// The configuration doesn't get validated, see NewObjectStoreChecked and New
 func NewObjectStore(c ObjectStoreConfig) ObjectStore {
-	return ObjectStore{
+	o := ObjectStore{
 		config:    c,
 		slabPools: make(map[uint8]*slabPool),
 	}
+	if c.CollectStats {
+		o.stats = &opStats{}
+	}
+	if c.Debug {
+		limit := c.QuarantineSlabs
+		if limit == 0 {
+			limit = defaultQuarantineSlabs
+		}
+		o.quarantine = &quarantine{limit: limit}
+	}
+	if c.OrderedIndex {
+		o.index = &orderedIndex{}
+	}
+	o.sizeClasses = newSizeClassTable(c.SizeClasses)
+	return o
 }
 
 // ObjAddr is a uintptr used for storing the addresses of objects in slabs
@@ -131,12 +245,7 @@ func slabFromSlabAddr(addr SlabAddr) *slab {
 // object as a byte slice.
 // it is important that the size is correct, otherwise anything can happen
 func objFromObjAddr(obj ObjAddr, size uint8) []byte {
-	var res []byte
-	resHeader := (*reflect.SliceHeader)(unsafe.Pointer(&res))
-	resHeader.Data = obj
-	resHeader.Len = int(size)
-	resHeader.Cap = resHeader.Len
-	return res
+	return unsafe.Slice((*byte)(unsafe.Pointer(obj)), size)
 }
 
 // objAddrFromObj takes and object and returns its address as an ObjAddr
@@ -151,17 +260,47 @@ func slabAddrFromSlab(slab *slab) SlabAddr {
 
 // Add takes an object and adds it to the slab pool of the correct size
 // On success it returns the memory address of the added object as an ObjAddr
-// On failure it returns an error as the second value
+// On failure it returns an error as the second value. If the object has
+// been stored, but protecting its frozen slab again failed, the address of
+// the object is returned together with the error
 func (o *ObjectStore) Add(obj []byte) (ObjAddr, error) {
+	return o.add(obj, 0)
+}
+
+// add is like Add, but it also stores the given expiry timestamp in Unix
+// nanoseconds if the Expiry is enabled
+func (o *ObjectStore) add(obj []byte, expiry int64) (ObjAddr, error) {
 	var oAddr ObjAddr
 	var sAddr SlabAddr
 
+	var start time.Time
+	if o.stats != nil {
+		start = time.Now()
+	}
+
 	// we only deal with objects up to a size of 255
 	if len(obj) == 0 || len(obj) > 255 {
 		return 0, fmt.Errorf("ObjectStore: Add failed because size of object (%d) is outside limits (1-%d)", len(obj), 255)
 	}
 
-	size := uint8(len(obj))
+	size := o.poolSizeFor(len(obj))
+
+	// with a memory limit we need to make sure that there is room for the
+	// object first, in the cache mode this may evict other objects
+	if o.config.MaxBytes > 0 {
+		if err := o.makeRoom(size); err != nil {
+			return 0, err
+		}
+	}
+
+	// the space in the index gets reserved before adding the object, so
+	// an added object can always be inserted into the index. This must
+	// happen after makeRoom, because evictions can shrink the index
+	if o.index != nil {
+		if err := o.index.reserve(); err != nil {
+			return 0, err
+		}
+	}
 
 	// get correct pool based on size of object
 	// if not found, create new pool for that size
@@ -174,13 +313,11 @@ func (o *ObjectStore) Add(obj []byte) (ObjAddr, error) {
 	// try to add the object to the pool
 	// there is potential for an error because this involves memory allocations
 	var err error
-	oAddr, sAddr, err = pool.add(obj, o.config.BaseObjectsPerSlab, o.config.GrowthFactor)
-	if err != nil {
-		return 0, err
-	}
+	oAddr, sAddr, err = pool.addWithExpiry(obj, o.config.BaseObjectsPerSlab, o.config.GrowthFactor, expiry)
 
 	// when sAddr != 0 this indicates that a new slab was created while adding the object
-	// we must update our lookup table to track the new slab
+	// we must update our lookup table to track the new slab, even if the pool
+	// failed to protect it again afterwards
 	if sAddr != 0 {
 		// we keep the lookup table sorted in descending order and insert new entries at an appropriate position
 		insertAt := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] < sAddr })
@@ -188,35 +325,89 @@ func (o *ObjectStore) Add(obj []byte) (ObjAddr, error) {
 		copy(o.lookupTable[insertAt+1:], o.lookupTable[insertAt:])
 		o.lookupTable[insertAt] = sAddr
 	}
+	if oAddr == 0 {
+		return 0, err
+	}
+
+	// the object has been stored even if the pool failed to protect the
+	// slab again afterwards, so it must be in the index in any case
+	if o.index != nil {
+		o.index.insert(oAddr, uint8(len(obj)))
+	}
+	if o.config.Observer != nil {
+		o.config.Observer.OnAdd(oAddr, objFromObjAddr(oAddr, uint8(len(obj))))
+	}
+
+	o.stats.add(start, sAddr != 0)
 
-	return oAddr, nil
+	return oAddr, err
 }
 
 // addSlabPool adds a slab pool of the specified size to this object store
 func (o *ObjectStore) addSlabPool(size uint8) {
-	o.slabPools[size] = NewSlabPool(size)
+	o.slabPools[size] = o.newSlabPool(size)
+}
+
+// newSlabPool creates a slab pool of the specified size with the settings
+// of this object store, without adding it
+func (o *ObjectStore) newSlabPool(size uint8) *slabPool {
+	pool := NewSlabPool(size)
+	pool.stats = o.stats
+	pool.observer = o.config.Observer
+	pool.slabFlags = o.config.slabFlagsFor(size, o.sizeClasses)
+	pool.maxObjsPerSlab = uint(o.config.MaxObjectsPerSlab)
+	pool.maxSlabBytes = o.config.MaxSlabBytes
+	pool.placement = o.config.Placement
+	pool.releaseEmptyPages = o.config.ReleasePages
+	pool.hugePages = o.config.HugePages && transparentHugePages
+	pool.quarantine = o.quarantine
+	pool.frozen = o.frozen
+	if o.config.CuckooFilter {
+		pool.filter = newCuckooFilter(cuckooMinBuckets)
+	}
+	return pool
 }
 
 // Search searches for the given value in the accordingly sized slab pool
 // On success it returns the object address and true
 // On failure it returns 0 and false
 func (o *ObjectStore) Search(searching []byte) (ObjAddr, bool) {
-	var obj ObjAddr
+	obj, success, _ := o.SearchContext(context.Background(), searching)
+	return obj, success
+}
 
-	size := uint8(len(searching))
-	pool, ok := o.slabPools[size]
+// SearchContext is like Search, but it stops searching once ctx is
+// cancelled or its deadline is exceeded. In that case it returns 0,
+// false and ctx.Err()
+func (o *ObjectStore) SearchContext(ctx context.Context, searching []byte) (ObjAddr, bool, error) {
+	var start time.Time
+	if o.stats != nil {
+		start = time.Now()
+	}
+
+	if len(searching) == 0 || len(searching) > 255 {
+		o.stats.search(start, false)
+		return 0, false, nil
+	}
+
+	pool, ok := o.slabPools[o.poolSizeFor(len(searching))]
 	if !ok {
 		// there is no pool for the size of the searched object,
 		// so we can directly give up
-		return 0, false
+		o.stats.search(start, false)
+		return 0, false, nil
 	}
 
-	obj, success := pool.search(searching)
+	obj, success, err := pool.searchContext(ctx, searching)
+	if err != nil {
+		return 0, false, err
+	}
+	o.stats.search(start, success)
 	if !success {
-		return 0, false
+		return 0, false, nil
 	}
 
-	return obj, true
+	return obj, true, nil
 }
 
 // Get retrieves a value by object address
@@ -228,9 +419,19 @@ func (o *ObjectStore) Get(obj ObjAddr) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if o.config.Debug {
+		if err = checkObjAddr(obj, sAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	o.stats.get()
 
-	slab := slabFromSlabAddr(sAddr)
-	return objFromObjAddr(obj, slab.objSize), nil
+	s := slabFromSlabAddr(sAddr)
+	if o.config.Cache {
+		o.slabPools[s.objSize].touch(s, obj)
+	}
+	return s.objAt(obj), nil
 }
 
 // Delete deletes an object by object address
@@ -244,12 +445,49 @@ func (o *ObjectStore) Delete(obj ObjAddr) error {
 	if err != nil {
 		return err
 	}
+	if o.config.Debug {
+		if err = checkObjAddr(obj, slabAddr); err != nil {
+			return err
+		}
+	}
+
+	// deleting an object twice must neither notify the observer nor
+	// touch the index and the filter, which may refer to other objects
+	// with the same value
+	if s := slabFromSlabAddr(slabAddr); !s.isUsed(s.getObjIdx(obj)) {
+		return fmt.Errorf("ObjectStore: Delete failed because object 0x%x has already been deleted", obj)
+	}
 
 	size := slabFromSlabAddr(slabAddr).objSize
+
+	// the index entry needs to be located while the object is still there
+	var indexIdx int
+	if o.index != nil {
+		var found bool
+		indexIdx, found = o.index.find(obj, slabFromSlabAddr(slabAddr).objLen(obj))
+		if !found {
+			return fmt.Errorf("ObjectStore: Delete failed to locate object 0x%x in the index", obj)
+		}
+	}
+
+	// the observer only gets notified once the object has been deleted,
+	// by then its slot may be poisoned or its slab unmapped
+	var value []byte
+	if o.config.Observer != nil {
+		value = append([]byte(nil), slabFromSlabAddr(slabAddr).objAt(obj)...)
+	}
+
 	deleted, err = o.slabPools[size].delete(obj, slabAddr)
 	if err != nil {
 		return err
 	}
+	if o.index != nil {
+		o.index.removeAt(indexIdx)
+	}
+	if o.config.Observer != nil {
+		o.config.Observer.OnDelete(obj, value)
+	}
+	o.stats.delete()
 	if deleted {
 		// remove entry from slabPools
 		if len(o.slabPools[size].slabs) < 1 {
@@ -270,6 +508,91 @@ func (o *ObjectStore) Delete(obj ObjAddr) error {
 	return nil
 }
 
+// Replace changes the value of the object at the given address to the
+// given value. If the new value belongs to the same pool the object gets
+// overwritten in place and its address stays the same, otherwise the new
+// value gets added to its pool and the old object gets deleted
+// On success it returns the address of the object, which may have changed
+// On failure it returns an error. The old object is then still stored,
+// unless the error occurred while protecting a frozen slab again
+func (o *ObjectStore) Replace(obj ObjAddr, value []byte) (ObjAddr, error) {
+	if len(value) == 0 || len(value) > 255 {
+		return 0, fmt.Errorf("ObjectStore: Replace failed because size of object (%d) is outside limits (1-%d)", len(value), 255)
+	}
+
+	slabAddr, err := o.getSlabAddress(obj)
+	if err != nil {
+		return 0, err
+	}
+	if o.config.Debug {
+		if err = checkObjAddr(obj, slabAddr); err != nil {
+			return 0, err
+		}
+	}
+
+	currentSlab := slabFromSlabAddr(slabAddr)
+	if !currentSlab.isUsed(currentSlab.getObjIdx(obj)) {
+		return 0, fmt.Errorf("ObjectStore: Replace failed because object 0x%x has been deleted", obj)
+	}
+	size := currentSlab.objSize
+	if o.poolSizeFor(len(value)) != size {
+		// the moved object keeps its expiry timestamp
+		var expiry int64
+		if currentSlab.flags&slabFlagExpiry != 0 {
+			expiry = currentSlab.expiries()[currentSlab.getObjIdx(obj)]
+		}
+		o.pinned = obj
+		newObj, err := o.add(value, expiry)
+		o.pinned = 0
+		if err != nil {
+			if newObj != 0 {
+				// try to leave the store as it was
+				o.Delete(newObj)
+			}
+			return 0, err
+		}
+		if err = o.Delete(obj); err != nil {
+			// try to leave the store as it was
+			o.Delete(newObj)
+			return 0, err
+		}
+		return newObj, nil
+	}
+
+	// the index entry needs to be located while the old value is still there
+	var indexIdx int
+	if o.index != nil {
+		var found bool
+		indexIdx, found = o.index.find(obj, currentSlab.objLen(obj))
+		if !found {
+			return 0, fmt.Errorf("ObjectStore: Replace failed to locate object 0x%x in the index", obj)
+		}
+	}
+
+	// to an observer an overwritten object looks like a deleted object
+	// followed by an added object with the same address
+	var old []byte
+	if o.config.Observer != nil {
+		old = append([]byte(nil), currentSlab.objAt(obj)...)
+	}
+
+	// the index must be updated once the object has been overwritten, even
+	// if the pool failed to protect the slab again afterwards
+	replaced, err := o.slabPools[size].replace(obj, slabAddr, value)
+	if replaced && o.index != nil {
+		o.index.update(indexIdx, uint8(len(value)))
+	}
+	if replaced && o.config.Observer != nil {
+		o.config.Observer.OnDelete(obj, old)
+		o.config.Observer.OnAdd(obj, currentSlab.objAt(obj))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return obj, nil
+}
+
 // getObjectSize searches, in a descending order sorted slice, for a slab which is likely to contain
 // the object identified by the given address
 // On success it returns the slab address as SlabAddr and nil
@@ -280,5 +603,8 @@ func (o *ObjectStore) getSlabAddress(obj ObjAddr) (SlabAddr, error) {
 	if !ok {
 		return 0, fmt.Errorf("ObjectStore: getSlabAddr failed to locate size for the object address")
 	}
+	if !slabFromSlabAddr(o.lookupTable[idx]).contains(obj) {
+		return 0, fmt.Errorf("ObjectStore: getSlabAddr failed because object address 0x%x is not inside of a slab", obj)
+	}
 	return o.lookupTable[idx], nil
 }
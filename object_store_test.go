@@ -83,6 +83,42 @@ func TestAddingAndDeletingObjects(t *testing.T) {
 	})
 }
 
+func TestDeletingTwiceAfterTheSlabIsGone(t *testing.T) {
+	c := NewConfig()
+	c.GrowthFactor = 1
+	c.BaseObjectsPerSlab = 3
+
+	Convey("When all objects of the slab with the highest address got deleted", t, func() {
+		os := NewObjectStore(c)
+		var addrs []ObjAddr
+		for i := 0; i < 9; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		highest := os.lookupTable[0]
+		var deleted []ObjAddr
+		for _, objAddr := range addrs {
+			if objAddr > highest {
+				So(os.Delete(objAddr), ShouldBeNil)
+				deleted = append(deleted, objAddr)
+			}
+		}
+		So(deleted, ShouldHaveLength, 3)
+		So(os.lookupTable, ShouldHaveLength, 2)
+
+		Convey("deleting or replacing those objects again should fail", func() {
+			for _, objAddr := range deleted {
+				So(os.Delete(objAddr), ShouldNotBeNil)
+				_, err := os.Replace(objAddr, []byte("abcde"))
+				So(err, ShouldNotBeNil)
+			}
+			So(os.Len(), ShouldEqual, 6)
+			So(os.Verify(), ShouldBeNil)
+		})
+	})
+}
+
 func TestAddingAndDeletingLargeNumberOfObjects(t *testing.T) {
 	objectsPerSlab := uint(100)
 	expectedSlabs := uint(100)
@@ -146,7 +182,7 @@ func TestMemStats63Objects(t *testing.T) {
 	Convey("When using less than 64 objects per slab", t, func() {
 		memSize, err := os.MemStatsByObjSize(objectSize)
 		So(err, ShouldBeNil)
-		So(memSize, ShouldEqual, (1 + 32 + 8 + (10 * 63)))
+		So(memSize, ShouldEqual, (16 + 8 + (10 * 63)))
 	})
 }
 
@@ -168,7 +204,53 @@ func TestMemStats65Objects(t *testing.T) {
 	Convey("When using less than 64 objects per slab", t, func() {
 		memSize, err := os.MemStatsByObjSize(objectSize)
 		So(err, ShouldBeNil)
-		So(memSize, ShouldEqual, (1 + 32 + 16 + (10 * 65)))
+		So(memSize, ShouldEqual, (16 + 16 + (10 * 65)))
+	})
+}
+
+func TestCountStats(t *testing.T) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 10
+	c.GrowthFactor = 1
+	os := NewObjectStore(c)
+
+	Convey("When adding objects of two different sizes", t, func() {
+		var addrs []ObjAddr
+		for i := 0; i < 15; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		for i := 0; i < 4; i++ {
+			_, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the counts should reflect the stored objects", func() {
+			So(os.Len(), ShouldEqual, 19)
+			So(os.LenByObjSize(3), ShouldEqual, 15)
+			So(os.LenByObjSize(5), ShouldEqual, 4)
+			So(os.LenByObjSize(7), ShouldEqual, 0)
+
+			stats, err := os.CountStatsByObjSize(3)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, CountStat{ObjSize: 3, Objects: 15, Capacity: 20, Free: 5, Slabs: 2})
+			So(len(os.CountStatsPerPool()), ShouldEqual, 2)
+
+			_, err = os.CountStatsByObjSize(7)
+			So(err, ShouldNotBeNil)
+
+			Convey("and deleting objects should update them", func() {
+				for _, objAddr := range addrs[:12] {
+					So(os.Delete(objAddr), ShouldBeNil)
+				}
+				So(os.Len(), ShouldEqual, 7)
+
+				stats, err := os.CountStatsByObjSize(3)
+				So(err, ShouldBeNil)
+				So(stats, ShouldResemble, CountStat{ObjSize: 3, Objects: 3, Capacity: 10, Free: 7, Slabs: 1})
+			})
+		})
 	})
 }
 
@@ -218,3 +300,32 @@ func BenchmarkSearchingForValue(b *testing.B) {
 		}
 	}
 }
+
+func TestSearchingWithFingerprints(t *testing.T) {
+	c := NewConfig()
+	c.Fingerprints = true
+	os := NewObjectStore(c)
+
+	Convey("When adding objects to an object store with fingerprints", t, func() {
+		addrs := make(map[string]ObjAddr)
+		for i := 0; i < 1000; i++ {
+			value := fmt.Sprintf("%x", md5.Sum([]byte(strconv.Itoa(i))))[:1+i%20]
+			objAddr, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			addrs[value] = objAddr
+		}
+		So(os.Verify(), ShouldBeNil)
+
+		Convey("we should be able to search for them", func() {
+			for value := range addrs {
+				found, ok := os.Search([]byte(value))
+				So(ok, ShouldBeTrue)
+				obj, err := os.Get(found)
+				So(err, ShouldBeNil)
+				So(string(obj), ShouldEqual, value)
+			}
+			_, ok := os.Search([]byte("not-a-hex-value"))
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,39 @@
+package gos
+
+// Observer gets notified about the lifecycle of slabs and about the objects
+// which get added to and deleted from an object store. Its methods get
+// called synchronously by the modifying operation, so they must be fast and
+// must not modify the object store.
+// The values which get passed to OnAdd refer to the slab memory, they must
+// not be used after the method has returned. OnDelete gets a copy of the
+// deleted value
+type Observer interface {
+	// OnSlabCreated gets called after a slab has been mapped
+	OnSlabCreated(objSize uint8, addr SlabAddr, bytes uintptr)
+
+	// OnSlabDestroyed gets called after a slab has been unmapped, or
+	// quarantined in debug mode. The address must not be accessed anymore
+	OnSlabDestroyed(objSize uint8, addr SlabAddr, bytes uintptr)
+
+	// OnAdd gets called after an object has been added
+	OnAdd(obj ObjAddr, value []byte)
+
+	// OnDelete gets called after an object has been deleted
+	OnDelete(obj ObjAddr, value []byte)
+}
+
+// NopObserver implements Observer with methods that do nothing, it can be
+// embedded by observers which are only interested in some of the events
+type NopObserver struct{}
+
+// OnSlabCreated does nothing
+func (NopObserver) OnSlabCreated(uint8, SlabAddr, uintptr) {}
+
+// OnSlabDestroyed does nothing
+func (NopObserver) OnSlabDestroyed(uint8, SlabAddr, uintptr) {}
+
+// OnAdd does nothing
+func (NopObserver) OnAdd(ObjAddr, []byte) {}
+
+// OnDelete does nothing
+func (NopObserver) OnDelete(ObjAddr, []byte) {}
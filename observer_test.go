@@ -0,0 +1,120 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordingObserver records the events it gets notified about
+type recordingObserver struct {
+	slabs   map[SlabAddr]uintptr
+	mapped  uintptr
+	objects map[ObjAddr]string
+	adds    int
+	deletes int
+}
+
+func (r *recordingObserver) OnSlabCreated(objSize uint8, addr SlabAddr, bytes uintptr) {
+	r.slabs[addr] = bytes
+	r.mapped += bytes
+}
+
+func (r *recordingObserver) OnSlabDestroyed(objSize uint8, addr SlabAddr, bytes uintptr) {
+	if r.slabs[addr] == bytes {
+		delete(r.slabs, addr)
+	}
+	r.mapped -= bytes
+}
+
+func (r *recordingObserver) OnAdd(obj ObjAddr, value []byte) {
+	r.objects[obj] = string(value)
+	r.adds++
+}
+
+func (r *recordingObserver) OnDelete(obj ObjAddr, value []byte) {
+	if r.objects[obj] == string(value) {
+		delete(r.objects, obj)
+	}
+	r.deletes++
+}
+
+func TestObserver(t *testing.T) {
+	Convey("When modifying a store with an observer", t, func() {
+		observer := &recordingObserver{slabs: make(map[SlabAddr]uintptr), objects: make(map[ObjAddr]string)}
+		c := NewConfig()
+		c.Observer = observer
+		o := NewObjectStore(c)
+
+		addrs := make([]ObjAddr, 1000)
+		for i := range addrs {
+			var err error
+			addrs[i], err = o.Add([]byte(fmt.Sprintf("%d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("it should have seen every slab and object", func() {
+			So(observer.adds, ShouldEqual, 1000)
+			So(len(observer.slabs), ShouldEqual, len(o.lookupTable))
+			total, _ := o.MemStatsTotal()
+			So(observer.mapped, ShouldEqual, total)
+			for i, addr := range addrs {
+				So(observer.objects[addr], ShouldEqual, fmt.Sprintf("%d", i))
+			}
+		})
+
+		Convey("it should see replaced objects", func() {
+			addr, err := o.Replace(addrs[5], []byte("x"))
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, addrs[5])
+			So(observer.objects[addr], ShouldEqual, "x")
+
+			addr, err = o.Replace(addrs[6], []byte("xyz"))
+			So(err, ShouldBeNil)
+			So(observer.objects, ShouldNotContainKey, addrs[6])
+			So(observer.objects[addr], ShouldEqual, "xyz")
+		})
+
+		Convey("it should see deleted objects and destroyed slabs", func() {
+			for _, addr := range addrs {
+				So(o.Delete(addr), ShouldBeNil)
+			}
+			So(observer.deletes, ShouldEqual, 1000)
+			So(observer.objects, ShouldBeEmpty)
+			So(observer.slabs, ShouldBeEmpty)
+			So(observer.mapped, ShouldEqual, 0)
+		})
+	})
+
+	Convey("When deleting objects of a debug store with an observer", t, func() {
+		observer := &recordingObserver{slabs: make(map[SlabAddr]uintptr), objects: make(map[ObjAddr]string)}
+		c := NewConfig()
+		c.Debug = true
+		c.Observer = observer
+		o := NewObjectStore(c)
+		var addrs []ObjAddr
+		for i := 0; i < 100; i++ {
+			addr, err := o.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, addr)
+		}
+
+		Convey("it should get the values from before they got poisoned", func() {
+			for _, addr := range addrs {
+				So(o.Delete(addr), ShouldBeNil)
+			}
+			So(observer.deletes, ShouldEqual, 100)
+			So(observer.objects, ShouldBeEmpty)
+		})
+	})
+
+	Convey("NopObserver should implement Observer", t, func() {
+		c := NewConfig()
+		c.Observer = NopObserver{}
+		o := NewObjectStore(c)
+		addr, err := o.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		So(o.Delete(addr), ShouldBeNil)
+	})
+}
@@ -0,0 +1,182 @@
+package gos
+
+// Option modifies the configuration of an object store which gets created
+// by New
+type Option func(*ObjectStoreConfig)
+
+// New creates an object store with the default configuration, modified by
+// the given options. It returns an error if the resulting configuration is
+// invalid, see ObjectStoreConfig.Validate
+func New(opts ...Option) (ObjectStore, error) {
+	c := NewConfig()
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return NewObjectStoreChecked(c)
+}
+
+// WithConfig replaces the whole configuration, options which follow it
+// modify the given configuration
+func WithConfig(c ObjectStoreConfig) Option {
+	return func(config *ObjectStoreConfig) { *config = c }
+}
+
+// WithBaseObjectsPerSlab sets the number of objects of the first slab of
+// each pool
+func WithBaseObjectsPerSlab(base uint8) Option {
+	return func(c *ObjectStoreConfig) { c.BaseObjectsPerSlab = base }
+}
+
+// WithGrowthFactor sets the factor by which the slabs of a pool grow
+func WithGrowthFactor(growthFactor float64) Option {
+	return func(c *ObjectStoreConfig) { c.GrowthFactor = growthFactor }
+}
+
+// WithMaxObjectsPerSlab limits the number of objects of each new slab
+func WithMaxObjectsPerSlab(maxObjs uint32) Option {
+	return func(c *ObjectStoreConfig) { c.MaxObjectsPerSlab = maxObjs }
+}
+
+// WithMaxSlabBytes limits the number of bytes of each new slab
+func WithMaxSlabBytes(maxBytes uint64) Option {
+	return func(c *ObjectStoreConfig) { c.MaxSlabBytes = maxBytes }
+}
+
+// WithPlacement sets the policy which selects the slab that gets an
+// added object
+func WithPlacement(placement Placement) Option {
+	return func(c *ObjectStoreConfig) { c.Placement = placement }
+}
+
+// WithReleasePages makes Delete return the pages which contain no live
+// objects to the operating system
+func WithReleasePages() Option {
+	return func(c *ObjectStoreConfig) { c.ReleasePages = true }
+}
+
+// WithHugePages makes large slabs use transparent huge pages
+func WithHugePages() Option {
+	return func(c *ObjectStoreConfig) { c.HugePages = true }
+}
+
+// WithStats enables the collection of the operation counters and
+// latency histograms
+func WithStats() Option {
+	return func(c *ObjectStoreConfig) { c.CollectStats = true }
+}
+
+// WithFingerprints enables the per object fingerprints which speed up
+// searches
+func WithFingerprints() Option {
+	return func(c *ObjectStoreConfig) { c.Fingerprints = true }
+}
+
+// WithDebug enables the debug mode, emptied slabs are kept in a quarantine
+// of the given size, 0 selects the default size
+func WithDebug(quarantineSlabs int) Option {
+	return func(c *ObjectStoreConfig) {
+		c.Debug = true
+		c.QuarantineSlabs = quarantineSlabs
+	}
+}
+
+// WithOrderedIndex enables the ordered index which is required by Range
+// and ForEach
+func WithOrderedIndex() Option {
+	return func(c *ObjectStoreConfig) { c.OrderedIndex = true }
+}
+
+// WithCuckooFilter enables the per pool cuckoo filters which speed up
+// searches for absent objects
+func WithCuckooFilter() Option {
+	return func(c *ObjectStoreConfig) { c.CuckooFilter = true }
+}
+
+// WithSizeClasses enables the size class mode with the given classes
+func WithSizeClasses(classes ...uint8) Option {
+	return func(c *ObjectStoreConfig) { c.SizeClasses = classes }
+}
+
+// WithExpiry enables the per object expiry timestamps which are required
+// by AddWithTTL
+func WithExpiry() Option {
+	return func(c *ObjectStoreConfig) { c.Expiry = true }
+}
+
+// WithMaxBytes limits the number of bytes which get mmapped for slabs
+func WithMaxBytes(maxBytes uint64) Option {
+	return func(c *ObjectStoreConfig) { c.MaxBytes = maxBytes }
+}
+
+// WithCache enables the cache mode, onEvict gets called with each evicted
+// object and may be nil. The cache mode requires WithMaxBytes and can't be
+// combined with WithDebug
+func WithCache(onEvict func(ObjAddr, []byte)) Option {
+	return func(c *ObjectStoreConfig) {
+		c.Cache = true
+		c.OnEvict = onEvict
+	}
+}
+
+// WithObserver sets an Observer which gets notified about slab lifecycle
+// and object mutation events
+func WithObserver(observer Observer) Option {
+	return func(c *ObjectStoreConfig) { c.Observer = observer }
+}
+
+// Config returns the current configuration of the object store
+func (o *ObjectStore) Config() ObjectStoreConfig {
+	return o.config
+}
+
+// SetGrowth changes the number of objects of the first slab of each pool
+// and the factor by which the slabs grow. Existing slabs keep their size,
+// the slabs which get created from now on use the new settings
+// It returns an error and keeps the current settings if the new ones are
+// invalid. Just like Add it must not be called concurrently with other
+// operations
+func (o *ObjectStore) SetGrowth(baseObjectsPerSlab uint8, growthFactor float64) error {
+	c := o.config
+	c.BaseObjectsPerSlab = baseObjectsPerSlab
+	c.GrowthFactor = growthFactor
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	o.config = c
+	return nil
+}
+
+// SetSlabLimits changes MaxObjectsPerSlab and MaxSlabBytes, which limit
+// the size of the slabs that get created from now on by all pools,
+// including the existing ones. Existing slabs keep their size
+// Just like Add it must not be called concurrently with other operations
+func (o *ObjectStore) SetSlabLimits(maxObjectsPerSlab uint32, maxSlabBytes uint64) error {
+	c := o.config
+	c.MaxObjectsPerSlab = maxObjectsPerSlab
+	c.MaxSlabBytes = maxSlabBytes
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	o.config = c
+	for _, pool := range o.slabPools {
+		pool.maxObjsPerSlab = uint(maxObjectsPerSlab)
+		pool.maxSlabBytes = maxSlabBytes
+	}
+	return nil
+}
+
+// SetMaxBytes changes the limit of the number of bytes which get mmapped
+// for slabs, 0 removes the limit unless the cache mode is enabled. Lowering
+// the limit doesn't unmap any slabs, it only affects the following adds
+// It returns an error and keeps the current limit if the new one is
+// invalid. Just like Add it must not be called concurrently with other
+// operations
+func (o *ObjectStore) SetMaxBytes(maxBytes uint64) error {
+	c := o.config
+	c.MaxBytes = maxBytes
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	o.config = c
+	return nil
+}
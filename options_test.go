@@ -0,0 +1,108 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNew(t *testing.T) {
+	Convey("When creating a store with options", t, func() {
+		o, err := New(
+			WithBaseObjectsPerSlab(10),
+			WithGrowthFactor(2),
+			WithStats(),
+			WithFingerprints(),
+			WithCuckooFilter(),
+			WithOrderedIndex(),
+			WithSizeClasses(8, 16),
+			WithExpiry(),
+			WithMaxBytes(1<<20),
+			WithCache(nil),
+			WithObserver(NopObserver{}),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("the options should be applied to the config", func() {
+			c := o.Config()
+			So(c.BaseObjectsPerSlab, ShouldEqual, 10)
+			So(c.GrowthFactor, ShouldEqual, 2)
+			So(c.CollectStats && c.Fingerprints && c.CuckooFilter && c.OrderedIndex && c.Expiry && c.Cache, ShouldBeTrue)
+			So(c.SizeClasses, ShouldResemble, []uint8{8, 16})
+			So(c.MaxBytes, ShouldEqual, 1<<20)
+			So(c.Observer, ShouldNotBeNil)
+
+			addr, err := o.Add([]byte("abc"))
+			So(err, ShouldBeNil)
+			So(o.slabPools[8].slabs[0].objCount(), ShouldEqual, 10)
+			So(o.Delete(addr), ShouldBeNil)
+		})
+	})
+
+	Convey("Options which are applied after WithConfig should modify it", t, func() {
+		c := NewConfig()
+		c.BaseObjectsPerSlab = 50
+		o, err := New(WithConfig(c), WithDebug(2))
+		So(err, ShouldBeNil)
+		So(o.Config().BaseObjectsPerSlab, ShouldEqual, 50)
+		So(o.Config().Debug, ShouldBeTrue)
+		So(o.Config().QuarantineSlabs, ShouldEqual, 2)
+	})
+
+	Convey("Invalid options should be rejected", t, func() {
+		_, err := New(WithGrowthFactor(0.5))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithCache(nil))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestRuntimeTuning(t *testing.T) {
+	Convey("When changing the growth settings of a store", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(10), WithGrowthFactor(1))
+		So(err, ShouldBeNil)
+		for i := 0; i < 20; i++ {
+			_, err = o.Add([]byte("abc"))
+			So(err, ShouldBeNil)
+		}
+		So(o.SetGrowth(40, 2), ShouldBeNil)
+		for i := 0; i < 40; i++ {
+			_, err = o.Add([]byte("abc"))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("only the new slabs should use the new settings", func() {
+			var objCounts []uint
+			for _, s := range o.slabPools[3].slabs {
+				objCounts = append(objCounts, s.objCount())
+			}
+			So(objCounts, ShouldHaveLength, 3)
+			So(objCounts, ShouldContain, uint(10))
+			// 40 + 20 live objects * (2 - 1)
+			So(objCounts, ShouldContain, uint(60))
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("invalid settings should be rejected", func() {
+			So(o.SetGrowth(0, 2), ShouldNotBeNil)
+			So(o.Config().BaseObjectsPerSlab, ShouldEqual, 40)
+		})
+	})
+
+	Convey("When lowering the memory limit of a store", t, func() {
+		o, err := New()
+		So(err, ShouldBeNil)
+		_, err = o.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		mapped := o.mappedBytes()
+		So(o.SetMaxBytes(mapped), ShouldBeNil)
+
+		Convey("adds which need a new slab should fail", func() {
+			_, err = o.Add([]byte("abcd"))
+			So(err, ShouldNotBeNil)
+			So(o.SetMaxBytes(0), ShouldBeNil)
+			_, err = o.Add([]byte("abcd"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
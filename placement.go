@@ -0,0 +1,71 @@
+package gos
+
+import "fmt"
+
+// Placement selects the slab of a pool which gets the added objects, as
+// long as there is a slab with a free object slot
+type Placement uint8
+
+const (
+	// PlacementFirstFit adds objects to the first slab with a free slot,
+	// in the order of the slab addresses from the highest to the lowest
+	PlacementFirstFit Placement = iota
+
+	// PlacementMostFull adds objects to the slab with the largest share of
+	// used slots. This lets sparse slabs drain to empty, so they get
+	// unmapped, for the cost of iterating over the slabs with free slots
+	PlacementMostFull
+
+	// PlacementLargest adds objects to the slab with the most object
+	// slots, which usually is the newest one. Like PlacementMostFull it
+	// iterates over the slabs with free slots
+	PlacementLargest
+)
+
+// String returns the name of the placement policy
+func (p Placement) String() string {
+	switch p {
+	case PlacementFirstFit:
+		return "first-fit"
+	case PlacementMostFull:
+		return "most-full"
+	case PlacementLargest:
+		return "largest"
+	}
+	return fmt.Sprintf("Placement(%d)", uint8(p))
+}
+
+// pickSlab returns the index of the slab which gets the next added object
+// according to the placement policy of the pool
+// The second return value is false if all slabs are full
+func (s *slabPool) pickSlab() (uint, bool) {
+	slabCount := uint(len(s.slabs))
+	first, found := s.freeSlabs.NextClear(0)
+	if !found || first >= slabCount {
+		return 0, false
+	}
+	if s.placement == PlacementFirstFit {
+		return first, true
+	}
+
+	best := first
+	for idx, ok := s.freeSlabs.NextClear(first + 1); ok && idx < slabCount; idx, ok = s.freeSlabs.NextClear(idx + 1) {
+		if s.placement.prefers(s.slabs[idx], s.slabs[best]) {
+			best = idx
+		}
+	}
+	return best, true
+}
+
+// prefers returns true if the object should rather be added to slab a
+// than to slab b, both of which must have a free slot
+func (p Placement) prefers(a, b *slab) bool {
+	switch p {
+	case PlacementMostFull:
+		// compares a.live / a.objCount() to b.live / b.objCount()
+		return uint64(a.live)*uint64(b.objCount()) > uint64(b.live)*uint64(a.objCount())
+	case PlacementLargest:
+		return a.objCount() > b.objCount()
+	}
+	return false
+}
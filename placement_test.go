@@ -0,0 +1,136 @@
+package gos
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPlacement(t *testing.T) {
+	Convey("When a pool has three slabs with different fill levels", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(10), WithGrowthFactor(2))
+		So(err, ShouldBeNil)
+
+		// the slabs have 10, 20 and 40 object slots
+		var addrs []ObjAddr
+		for i := 0; i < 70; i++ {
+			objAddr, err := o.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		pool := o.slabPools[5]
+		So(len(pool.slabs), ShouldEqual, 3)
+
+		// leave 9/10, 5/20 and 30/40 objects in the slabs
+		keep := map[uint]uint32{10: 9, 20: 5, 40: 30}
+		for _, objAddr := range addrs {
+			s := pool.slabs[pool.findSlabByAddr(objAddr)]
+			if s.live > keep[s.objCount()] {
+				So(o.Delete(objAddr), ShouldBeNil)
+			}
+		}
+		slabWith := func(objCount uint) *slab {
+			for _, s := range pool.slabs {
+				if s.objCount() == objCount {
+					return s
+				}
+			}
+			return nil
+		}
+		addedTo := func(placement Placement) *slab {
+			pool.placement = placement
+			objAddr, err := o.Add([]byte("abcde"))
+			So(err, ShouldBeNil)
+			return pool.slabs[pool.findSlabByAddr(objAddr)]
+		}
+
+		Convey("first-fit should pick the slab at the highest address", func() {
+			So(addedTo(PlacementFirstFit), ShouldEqual, pool.slabs[0])
+		})
+
+		Convey("most-full should pick the slab with the largest share of used slots", func() {
+			So(addedTo(PlacementMostFull), ShouldEqual, slabWith(10))
+			// now the slab of 10 is full
+			So(addedTo(PlacementMostFull), ShouldEqual, slabWith(40))
+		})
+
+		Convey("largest should pick the slab with the most slots", func() {
+			So(addedTo(PlacementLargest), ShouldEqual, slabWith(40))
+		})
+
+		Convey("a new slab should only be added once all slabs are full", func() {
+			for i := 0; i < 26; i++ {
+				addedTo(PlacementMostFull)
+			}
+			So(len(pool.slabs), ShouldEqual, 3)
+			addedTo(PlacementMostFull)
+			So(len(pool.slabs), ShouldEqual, 4)
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+
+	Convey("Unknown placements should be rejected", t, func() {
+		_, err := New(WithPlacement(PlacementLargest + 1))
+		So(err, ShouldNotBeNil)
+		So(PlacementMostFull.String(), ShouldEqual, "most-full")
+	})
+}
+
+// benchmarkChurn deletes a random object and adds a new one in each
+// iteration, after filling the store with 100000 objects and deleting half
+// of them. It reports the fragmentation and the mapped memory at the end
+func benchmarkChurn(b *testing.B, placement Placement) {
+	o, err := New(WithPlacement(placement))
+	if err != nil {
+		b.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	var addrs []ObjAddr
+	add := func(i int) {
+		objAddr, err := o.Add([]byte(fmt.Sprintf("%10d", i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		addrs = append(addrs, objAddr)
+	}
+	deleteRandom := func() {
+		idx := rnd.Intn(len(addrs))
+		if err := o.Delete(addrs[idx]); err != nil {
+			b.Fatal(err)
+		}
+		addrs[idx] = addrs[len(addrs)-1]
+		addrs = addrs[:len(addrs)-1]
+	}
+
+	for i := 0; i < 100000; i++ {
+		add(i)
+	}
+	for i := 0; i < 50000; i++ {
+		deleteRandom()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		deleteRandom()
+		add(i)
+	}
+	b.StopTimer()
+
+	frag, err := o.FragStatsTotal()
+	if err != nil {
+		b.Fatal(err)
+	}
+	mem, err := o.MemStatsTotal()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(frag), "frag")
+	b.ReportMetric(float64(mem), "mapped-bytes")
+}
+
+func BenchmarkChurnFirstFit(b *testing.B) { benchmarkChurn(b, PlacementFirstFit) }
+func BenchmarkChurnMostFull(b *testing.B) { benchmarkChurn(b, PlacementMostFull) }
+func BenchmarkChurnLargest(b *testing.B)  { benchmarkChurn(b, PlacementLargest) }
@@ -0,0 +1,11 @@
+//go:build linux || darwin
+
+package gos
+
+import "syscall"
+
+// mprotect sets the protection of the given page aligned memory area to the
+// given syscall.PROT_* flags
+func mprotect(mem []byte, prot int) error {
+	return syscall.Mprotect(mem, prot)
+}
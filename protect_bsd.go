@@ -0,0 +1,22 @@
+//go:build dragonfly || freebsd || netbsd || openbsd
+
+package gos
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// mprotect sets the protection of the given page aligned memory area to the
+// given syscall.PROT_* flags. The syscall package of the BSDs has no
+// Mprotect, so it uses the raw syscall
+func mprotect(mem []byte, prot int) error {
+	if len(mem) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MPROTECT, uintptr(unsafe.Pointer(&mem[0])), uintptr(len(mem)), uintptr(prot))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
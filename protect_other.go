@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package gos
+
+import "fmt"
+
+// mprotect fails on the platforms without a usable mprotect syscall, so
+// the Debug mode and Freeze aren't supported there
+func mprotect(mem []byte, prot int) error {
+	return fmt.Errorf("mprotect: changing the protection of memory is not supported on this platform")
+}
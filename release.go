@@ -0,0 +1,157 @@
+package gos
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// anyUsed returns true if one of the object slots from first to last,
+// including both, is in use
+func (s *slab) anyUsed(first, last uint) bool {
+	bitmap := s.bitmap()
+	for w := first / 64; w <= last/64; w++ {
+		word := bitmap[w]
+		if w == first/64 {
+			word &= ^uint64(0) << (first % 64)
+		}
+		if w == last/64 {
+			word &= ^uint64(0) >> (63 - last%64)
+		}
+		if word != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pageHasLive returns true if the page at the given address, which must be
+// in the data region of this slab, overlaps with a live object slot
+func (s *slab) pageHasLive(page uintptr) bool {
+	dataStart := s.addr() + s.getDataOffset()
+	dataEnd := s.addr() + s.getTotalLength()
+	if page >= dataEnd {
+		// the rest of the last page isn't used by the slab
+		return false
+	}
+	end := page + pageSize
+	if end > dataEnd {
+		end = dataEnd
+	}
+	first := (page - dataStart) / s.slotSize()
+	last := (end - 1 - dataStart) / s.slotSize()
+	return s.anyUsed(uint(first), uint(last))
+}
+
+// releasePages releases the pages from start to end, which must be page
+// aligned, that contain no live objects with madvise(MADV_DONTNEED). The
+// kernel drops the released pages, they get faulted in again as zeroed
+// pages once an added object gets written into one of their slots
+// Pages that contain slab metadata are never released, neither are the
+// pages of debug slabs because they keep the poisoned deleted objects,
+// nor the pages of huge page slabs because releasing a part of a huge page
+// makes the kernel split it into normal pages
+// It returns the number of released bytes
+func (s *slab) releasePages(start, end uintptr) (uint64, error) {
+	if s.flags&(slabFlagDebug|slabFlagHugePages) != 0 {
+		return 0, nil
+	}
+	if first := roundUpToPage(s.addr() + s.getDataOffset()); start < first {
+		start = first
+	}
+	if last := roundUpToPage(s.addr() + s.getTotalLength()); end > last {
+		end = last
+	}
+
+	var released uint64
+	for page := start; page < end; {
+		if s.pageHasLive(page) {
+			page += pageSize
+			continue
+		}
+		runStart := page
+		for page < end && !s.pageHasLive(page) {
+			page += pageSize
+		}
+		run, err := dropPages(unsafe.Slice((*byte)(unsafe.Pointer(runStart)), page-runStart))
+		released += run
+		if err != nil {
+			return released, err
+		}
+	}
+	return released, nil
+}
+
+// releaseAll releases all pages of this slab which contain no live objects
+func (s *slab) releaseAll() (uint64, error) {
+	return s.releasePages(s.addr(), roundUpToPage(s.addr()+s.getTotalLength()))
+}
+
+// releaseSlot releases the pages which overlap with the object slot at the
+// given index, if they contain no live objects
+func (s *slab) releaseSlot(idx uint) (uint64, error) {
+	slotStart := s.addr() + s.getDataOffset() + uintptr(idx)*s.slotSize()
+	return s.releasePages(slotStart&^(pageSize-1), roundUpToPage(slotStart+s.slotSize()))
+}
+
+// residentBytes returns the number of bytes of the slabs of this pool which
+// are resident in RAM
+func (s *slabPool) residentBytes() (uint64, error) {
+	var total uint64
+	for _, sl := range s.slabs {
+		resident, err := residentBytes(sl.region())
+		if err != nil {
+			return total, err
+		}
+		total += resident
+	}
+	return total, nil
+}
+
+// ReleaseMemory returns the pages of all slabs which contain no live objects
+// to the operating system, so that sparse slabs don't keep all of their
+// pages resident. The released pages stay mapped, they get faulted in again
+// when objects get added into their slots. See ObjectStoreConfig.ReleasePages
+// to release pages as soon as they become empty
+// It returns the number of released bytes, including pages which have
+// already been released before or which have never been touched
+func (o *ObjectStore) ReleaseMemory() (uint64, error) {
+	var total uint64
+	for _, pool := range o.slabPools {
+		for _, sl := range pool.slabs {
+			if sl.live == sl.slots {
+				continue
+			}
+			released, err := sl.releaseAll()
+			total += released
+			if err != nil {
+				return total, fmt.Errorf("ObjectStore: ReleaseMemory failed to release the pages of slab 0x%x: %s", sl.addr(), err)
+			}
+		}
+	}
+	return total, nil
+}
+
+// MemStatsResidentByObjSize returns the number of bytes of the slabs of the
+// pool with the given object size which are resident in RAM
+func (o *ObjectStore) MemStatsResidentByObjSize(size uint8) (uint64, error) {
+	pool, ok := o.slabPools[size]
+	if !ok {
+		return 0, fmt.Errorf("ObjectStore: MemStatsResidentByObjSize failed to find pool with object size %d", size)
+	}
+	return pool.residentBytes()
+}
+
+// MemStatsResidentTotal returns the number of bytes of all slabs which are
+// resident in RAM. Unlike MemStatsTotal it doesn't include the pages which
+// have been released or have never been touched
+func (o *ObjectStore) MemStatsResidentTotal() (uint64, error) {
+	var total uint64
+	for _, p := range o.slabPools {
+		resident, err := p.residentBytes()
+		total += resident
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,36 @@
+package gos
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// dropPages returns the given page aligned memory area to the operating
+// system with madvise(MADV_DONTNEED), the pages get faulted in again as
+// zeroed pages when they get accessed
+// It returns the number of released bytes
+func dropPages(mem []byte) (uint64, error) {
+	if err := syscall.Madvise(mem, syscall.MADV_DONTNEED); err != nil {
+		return 0, err
+	}
+	return uint64(len(mem)), nil
+}
+
+// residentBytes returns the number of bytes of the given page aligned
+// memory area which are resident in RAM, according to mincore
+func residentBytes(mem []byte) (uint64, error) {
+	if len(mem) == 0 {
+		return 0, nil
+	}
+	vec := make([]byte, (uintptr(len(mem))+pageSize-1)/pageSize)
+	_, _, errno := syscall.Syscall(syscall.SYS_MINCORE, uintptr(unsafe.Pointer(&mem[0])), uintptr(len(mem)), uintptr(unsafe.Pointer(&vec[0])))
+	if errno != 0 {
+		return 0, errno
+	}
+
+	var pages uint64
+	for _, v := range vec {
+		pages += uint64(v & 1)
+	}
+	return pages * uint64(pageSize), nil
+}
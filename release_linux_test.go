@@ -0,0 +1,117 @@
+package gos
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReleaseMemory(t *testing.T) {
+	// each slab has 255 slots of 200 bytes, which span about 12 pages
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte(fmt.Sprintf("%4d", i)), 50)
+	}
+	fill := func(o *ObjectStore) []ObjAddr {
+		var addrs []ObjAddr
+		for i := 0; i < 255; i++ {
+			objAddr, err := o.Add(value(i))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		return addrs
+	}
+
+	Convey("When all but the first and the last object of a slab got deleted", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(255), WithGrowthFactor(1))
+		So(err, ShouldBeNil)
+		addrs := fill(&o)
+		for _, objAddr := range addrs[1:254] {
+			So(o.Delete(objAddr), ShouldBeNil)
+		}
+		before, err := o.MemStatsResidentTotal()
+		So(err, ShouldBeNil)
+		mapped, err := o.MemStatsTotal()
+		So(err, ShouldBeNil)
+		So(before, ShouldBeLessThanOrEqualTo, roundUpToPage(uintptr(mapped)))
+
+		Convey("ReleaseMemory should release the pages without live objects", func() {
+			released, err := o.ReleaseMemory()
+			So(err, ShouldBeNil)
+			So(released, ShouldBeGreaterThanOrEqualTo, 8*uint64(pageSize))
+
+			after, err := o.MemStatsResidentTotal()
+			So(err, ShouldBeNil)
+			So(after, ShouldBeLessThan, before)
+			So(o.MemStatsPerPool()[0].Resident, ShouldEqual, after)
+			resident, err := o.MemStatsResidentByObjSize(200)
+			So(err, ShouldBeNil)
+			So(resident, ShouldEqual, after)
+			_, err = o.MemStatsResidentByObjSize(100)
+			So(err, ShouldNotBeNil)
+
+			// the remaining objects are untouched
+			for _, i := range []int{0, 254} {
+				obj, err := o.Get(addrs[i])
+				So(err, ShouldBeNil)
+				So(obj, ShouldResemble, value(i))
+			}
+
+			Convey("the released slots should be reusable", func() {
+				var readded []ObjAddr
+				for i := 1; i < 254; i++ {
+					objAddr, err := o.Add(value(i))
+					So(err, ShouldBeNil)
+					readded = append(readded, objAddr)
+				}
+				So(len(o.slabPools[200].slabs), ShouldEqual, 1)
+				for i, objAddr := range readded {
+					obj, err := o.Get(objAddr)
+					So(err, ShouldBeNil)
+					So(obj, ShouldResemble, value(i+1))
+				}
+				So(o.Verify(), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("With ReleasePages, Delete should release pages once they are empty", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(255), WithGrowthFactor(1), WithReleasePages(), WithFingerprints())
+		So(err, ShouldBeNil)
+		addrs := fill(&o)
+		before, err := o.MemStatsResidentTotal()
+		So(err, ShouldBeNil)
+
+		for _, objAddr := range addrs[1:254] {
+			So(o.Delete(objAddr), ShouldBeNil)
+		}
+		after, err := o.MemStatsResidentTotal()
+		So(err, ShouldBeNil)
+		So(after, ShouldBeLessThan, before)
+
+		released, err := o.ReleaseMemory()
+		So(err, ShouldBeNil)
+		afterRelease, err := o.MemStatsResidentTotal()
+		So(err, ShouldBeNil)
+		So(released, ShouldBeGreaterThan, 0)
+		So(afterRelease, ShouldEqual, after)
+
+		objAddr, found := o.Search(value(254))
+		So(found, ShouldBeTrue)
+		So(objAddr, ShouldEqual, addrs[254])
+		So(o.Verify(), ShouldBeNil)
+	})
+
+	Convey("Debug slabs should keep their pages", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(255), WithGrowthFactor(1), WithDebug(0))
+		So(err, ShouldBeNil)
+		addrs := fill(&o)
+		for _, objAddr := range addrs[1:] {
+			So(o.Delete(objAddr), ShouldBeNil)
+		}
+		released, err := o.ReleaseMemory()
+		So(err, ShouldBeNil)
+		So(released, ShouldEqual, 0)
+	})
+}
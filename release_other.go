@@ -0,0 +1,15 @@
+//go:build !linux
+
+package gos
+
+// dropPages doesn't release any pages, because MADV_DONTNEED only drops
+// the pages right away on Linux
+func dropPages(mem []byte) (uint64, error) {
+	return 0, nil
+}
+
+// residentBytes counts every page of the given memory area as resident,
+// because only Linux gets asked with mincore
+func residentBytes(mem []byte) (uint64, error) {
+	return uint64(len(mem)), nil
+}
@@ -0,0 +1,22 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAnyUsed(t *testing.T) {
+	Convey("anyUsed should only look at the given range of slots", t, func() {
+		s, err := newSlab(1, 200)
+		So(err, ShouldBeNil)
+		defer s.unmap()
+		s.setUsed(70)
+
+		So(s.anyUsed(0, 69), ShouldBeFalse)
+		So(s.anyUsed(71, 199), ShouldBeFalse)
+		So(s.anyUsed(70, 70), ShouldBeTrue)
+		So(s.anyUsed(0, 199), ShouldBeTrue)
+		So(s.anyUsed(64, 127), ShouldBeTrue)
+	})
+}
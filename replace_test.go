@@ -0,0 +1,100 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplace(t *testing.T) {
+	Convey("When replacing objects in a store with all optional features", t, func() {
+		c := NewConfig()
+		c.Fingerprints = true
+		c.CuckooFilter = true
+		c.OrderedIndex = true
+		o := NewObjectStore(c)
+
+		addrs := make([]ObjAddr, 100)
+		for i := range addrs {
+			var err error
+			addrs[i], err = o.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("a value of the same size should be written in place", func() {
+			addr, err := o.Replace(addrs[10], []byte("abc"))
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, addrs[10])
+			obj, _ := o.Get(addr)
+			So(string(obj), ShouldEqual, "abc")
+
+			found, ok := o.Search([]byte("abc"))
+			So(ok, ShouldBeTrue)
+			So(found, ShouldEqual, addr)
+			_, ok = o.Search([]byte("010"))
+			So(ok, ShouldBeFalse)
+
+			var last []byte
+			err = o.ForEach(func(_ ObjAddr, value []byte) bool {
+				last = value
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(string(last), ShouldEqual, "abc")
+			So(o.Len(), ShouldEqual, 100)
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("a value of a different size should be moved to its pool", func() {
+			addr, err := o.Replace(addrs[10], []byte("abcdef"))
+			So(err, ShouldBeNil)
+			So(addr, ShouldNotEqual, addrs[10])
+			obj, _ := o.Get(addr)
+			So(string(obj), ShouldEqual, "abcdef")
+			So(o.LenByObjSize(3), ShouldEqual, 99)
+			So(o.LenByObjSize(6), ShouldEqual, 1)
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("replacing the only object of a slab should delete the slab", func() {
+			addr, err := o.Replace(addrs[10], []byte("abcdef"))
+			So(err, ShouldBeNil)
+			addr, err = o.Replace(addr, []byte("abcdefg"))
+			So(err, ShouldBeNil)
+			So(o.LenByObjSize(6), ShouldEqual, 0)
+			So(len(o.lookupTable), ShouldEqual, len(o.slabPools[3].slabs)+1)
+			So(o.Verify(), ShouldBeNil)
+		})
+
+		Convey("an invalid value should be rejected", func() {
+			_, err := o.Replace(addrs[10], nil)
+			So(err, ShouldNotBeNil)
+			obj, _ := o.Get(addrs[10])
+			So(string(obj), ShouldEqual, "010")
+		})
+	})
+
+	Convey("When replacing objects in a frozen store with size classes", t, func() {
+		c := NewConfig()
+		c.SizeClasses = []uint8{8, 16}
+		o := NewObjectStore(c)
+		addr, err := o.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		So(o.Freeze(), ShouldBeNil)
+
+		Convey("a value of the same class should be written in place", func() {
+			newAddr, err := o.Replace(addr, []byte("abcdefgh"))
+			So(err, ShouldBeNil)
+			So(newAddr, ShouldEqual, addr)
+			obj, _ := o.Get(addr)
+			So(string(obj), ShouldEqual, "abcdefgh")
+
+			newAddr, err = o.Replace(addr, []byte("abcdefghi"))
+			So(err, ShouldBeNil)
+			So(newAddr, ShouldNotEqual, addr)
+			So(o.Verify(), ShouldBeNil)
+			So(o.Unfreeze(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package gos
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelScanThreshold is the number of object slots below which slabs
+// get scanned by the calling goroutine, because for small pools the
+// overhead of handing the work to the scan workers dominates
+const parallelScanThreshold = 1 << 16
+
+// scanWorkers is a bounded pool of goroutines which scan slabs on behalf of
+// searches. It is shared by all object stores and gets started on first use
+var scanWorkers struct {
+	once  sync.Once
+	tasks chan func()
+}
+
+// runOnScanWorkers runs the given task on one of the scan workers. If no
+// worker is idle the task runs on the calling goroutine instead, so that
+// scans which get started by a task, for example by a Find predicate that
+// calls Search, never wait for the workers which are busy running their
+// callers
+func runOnScanWorkers(task func()) {
+	scanWorkers.once.Do(func() {
+		workers := runtime.GOMAXPROCS(0)
+		scanWorkers.tasks = make(chan func())
+		for i := 0; i < workers; i++ {
+			go func() {
+				for task := range scanWorkers.tasks {
+					task()
+				}
+			}()
+		}
+	})
+
+	select {
+	case scanWorkers.tasks <- task:
+	default:
+		task()
+	}
+}
+
+// scanSlabs calls scan for each of the given slabs until scan returns
+// false or ctx is done. Large sets of slabs get scanned in parallel by the
+// scan workers, so scan must be safe for concurrent use.
+// scanSlabs only returns once no more scan calls are running, so the slabs
+// may be modified afterwards. It returns ctx.Err() if the scan has been
+// stopped because ctx is done, otherwise it returns nil
+func scanSlabs(ctx context.Context, slabs []*slab, scan func(*slab) bool) error {
+	var slots uint
+	for _, sl := range slabs {
+		slots += sl.objCount()
+	}
+
+	if slots < parallelScanThreshold || len(slabs) < 2 {
+		for _, sl := range slabs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !scan(sl) {
+				return nil
+			}
+		}
+		return ctx.Err()
+	}
+
+	var wg sync.WaitGroup
+	var next int64 = -1
+	var stopped int32
+	done := ctx.Done()
+
+	tasks := runtime.GOMAXPROCS(0)
+	if tasks > len(slabs) {
+		tasks = len(slabs)
+	}
+	for i := 0; i < tasks && ctx.Err() == nil; i++ {
+		wg.Add(1)
+		runOnScanWorkers(func() {
+			defer wg.Done()
+
+			// every task keeps taking the next slab that hasn't been scanned
+			for atomic.LoadInt32(&stopped) == 0 {
+				select {
+				case <-done:
+					atomic.StoreInt32(&stopped, 1)
+					return
+				default:
+				}
+
+				idx := int(atomic.AddInt64(&next, 1))
+				if idx >= len(slabs) {
+					return
+				}
+				if !scan(slabs[idx]) {
+					atomic.StoreInt32(&stopped, 1)
+					return
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
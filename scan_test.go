@@ -0,0 +1,118 @@
+package gos
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScanSlabs(t *testing.T) {
+	Convey("When scanning a small and a large set of slabs", t, func() {
+		small := NewSlabPool(4)
+		large := NewSlabPool(4)
+		for i := 0; i < 1000; i++ {
+			_, _, err := small.add([]byte(fmt.Sprintf("%04d", i)), 100, 1)
+			So(err, ShouldBeNil)
+		}
+		for i := 0; i < parallelScanThreshold*2; i++ {
+			_, _, err := large.add([]byte(fmt.Sprintf("%04d", i%10000)), 255, 1)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("every slab should get scanned exactly once", func() {
+			for _, pool := range []*slabPool{small, large} {
+				var scanned int64
+				seen := make([]int32, len(pool.slabs))
+				err := scanSlabs(context.Background(), pool.slabs, func(sl *slab) bool {
+					atomic.AddInt64(&scanned, 1)
+					atomic.AddInt32(&seen[pool.findSlabByAddr(sl.addr())], 1)
+					return true
+				})
+				So(err, ShouldBeNil)
+				So(scanned, ShouldEqual, len(pool.slabs))
+				for _, count := range seen {
+					So(count, ShouldEqual, 1)
+				}
+			}
+		})
+
+		Convey("the scan should stop when the callback returns false", func() {
+			var scanned int64
+			err := scanSlabs(context.Background(), large.slabs, func(sl *slab) bool {
+				atomic.AddInt64(&scanned, 1)
+				return false
+			})
+			So(err, ShouldBeNil)
+			So(scanned, ShouldBeLessThan, len(large.slabs))
+		})
+
+		Convey("the scan should stop when the context gets cancelled", func() {
+			for _, pool := range []*slabPool{small, large} {
+				ctx, cancel := context.WithCancel(context.Background())
+				var scanned int64
+				err := scanSlabs(ctx, pool.slabs, func(sl *slab) bool {
+					if atomic.AddInt64(&scanned, 1) == 2 {
+						cancel()
+					}
+					return true
+				})
+				So(err, ShouldEqual, context.Canceled)
+				So(scanned, ShouldBeLessThan, len(pool.slabs))
+			}
+		})
+
+		Convey("scans which get started by a scan callback should complete", func() {
+			var inner int64
+			finished := make(chan error, 1)
+			go func() {
+				finished <- scanSlabs(context.Background(), large.slabs, func(sl *slab) bool {
+					return scanSlabs(context.Background(), large.slabs, func(sl *slab) bool {
+						atomic.AddInt64(&inner, 1)
+						return true
+					}) == nil
+				})
+			}()
+
+			select {
+			case err := <-finished:
+				So(err, ShouldBeNil)
+				So(inner, ShouldEqual, len(large.slabs)*len(large.slabs))
+			case <-time.After(20 * time.Second):
+				So("the nested scans deadlocked", ShouldBeEmpty)
+			}
+		})
+	})
+}
+
+func TestSearchContext(t *testing.T) {
+	Convey("When searching an object store with a context", t, func() {
+		os := NewObjectStore(NewConfig())
+		objAddr, err := os.Add([]byte("abcde"))
+		So(err, ShouldBeNil)
+
+		Convey("a live context should find the object", func() {
+			found, ok, err := os.SearchContext(context.Background(), []byte("abcde"))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(found, ShouldEqual, objAddr)
+		})
+
+		Convey("a cancelled context should return its error", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, ok, err := os.SearchContext(ctx, []byte("abcde"))
+			So(err, ShouldEqual, context.Canceled)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("objects which are too long should never be found", func() {
+			_, ok, err := os.SearchContext(context.Background(), make([]byte, 256+5))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
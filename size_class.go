@@ -0,0 +1,60 @@
+package gos
+
+import "sort"
+
+// DefaultSizeClasses returns a set of size classes for
+// ObjectStoreConfig.SizeClasses. The classes get wider as they grow, above
+// 32 bytes less than a quarter of each object slot remains unused
+func DefaultSizeClasses() []uint8 {
+	return []uint8{8, 16, 24, 32, 40, 48, 64, 80, 96, 112, 128, 160, 192, 224, 255}
+}
+
+// sizeClassTable maps every object length to the object size of the pool
+// which stores objects of that length
+type sizeClassTable struct {
+	poolSizes [256]uint8
+	largest   uint8
+}
+
+// newSizeClassTable creates the table for the given size classes, which
+// don't need to be sorted. Objects which are larger than the largest
+// class are stored in pools of their exact length
+// It returns nil if there are no size classes
+func newSizeClassTable(classes []uint8) *sizeClassTable {
+	sorted := make([]int, 0, len(classes))
+	for _, class := range classes {
+		if class > 0 {
+			sorted = append(sorted, int(class))
+		}
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Ints(sorted)
+
+	t := &sizeClassTable{largest: uint8(sorted[len(sorted)-1])}
+	for length := 1; length < len(t.poolSizes); length++ {
+		if idx := sort.SearchInts(sorted, length); idx < len(sorted) {
+			t.poolSizes[length] = uint8(sorted[idx])
+		} else {
+			t.poolSizes[length] = uint8(length)
+		}
+	}
+	return t
+}
+
+// isSizeClass returns true if the pool with the given object size stores
+// the objects of a size class, rather than objects of exactly that length
+// Pools of exact lengths only exist above the largest class
+func (t *sizeClassTable) isSizeClass(size uint8) bool {
+	return t != nil && size <= t.largest
+}
+
+// poolSizeFor returns the object size of the pool which stores objects
+// of the given length, the length must not exceed 255
+func (o *ObjectStore) poolSizeFor(length int) uint8 {
+	if o.sizeClasses == nil {
+		return uint8(length)
+	}
+	return o.sizeClasses.poolSizes[length]
+}
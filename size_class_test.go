@@ -0,0 +1,134 @@
+package gos
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSizeClassTable(t *testing.T) {
+	Convey("When creating a size class table", t, func() {
+		table := newSizeClassTable([]uint8{32, 0, 8, 16})
+
+		Convey("lengths should map to the smallest class which fits them", func() {
+			So(table.poolSizes[1], ShouldEqual, 8)
+			So(table.poolSizes[8], ShouldEqual, 8)
+			So(table.poolSizes[9], ShouldEqual, 16)
+			So(table.poolSizes[32], ShouldEqual, 32)
+		})
+
+		Convey("lengths above the largest class should map to themselves", func() {
+			So(table.poolSizes[33], ShouldEqual, 33)
+			So(table.poolSizes[255], ShouldEqual, 255)
+			So(table.isSizeClass(32), ShouldBeTrue)
+			So(table.isSizeClass(33), ShouldBeFalse)
+		})
+	})
+
+	Convey("Without size classes there should be no table", t, func() {
+		So(newSizeClassTable(nil), ShouldBeNil)
+		So(newSizeClassTable([]uint8{0}), ShouldBeNil)
+	})
+}
+
+func TestSizeClasses(t *testing.T) {
+	Convey("When adding objects of many lengths to a store with size classes", t, func() {
+		c := NewConfig()
+		c.SizeClasses = []uint8{8, 16, 32, 64}
+		c.Fingerprints = true
+		c.CuckooFilter = true
+		c.OrderedIndex = true
+		o := NewObjectStore(c)
+
+		addrs := make(map[string]ObjAddr)
+		for length := 1; length <= 80; length++ {
+			for i := 0; i < 10; i++ {
+				value := fmt.Sprintf("%d-%s", i, strings.Repeat("x", length))[:length]
+				if _, ok := addrs[value]; ok {
+					continue
+				}
+				addr, err := o.Add([]byte(value))
+				So(err, ShouldBeNil)
+				addrs[value] = addr
+			}
+		}
+		So(o.Verify(), ShouldBeNil)
+
+		Convey("they should be stored in the pools of their classes", func() {
+			// 4 classes plus one pool for each length from 65 to 80
+			So(len(o.slabPools), ShouldEqual, 4+16)
+			for _, class := range []uint8{8, 16, 32, 64} {
+				So(o.slabPools[class].slabFlags&slabFlagLengthPrefix, ShouldNotEqual, 0)
+			}
+			So(o.slabPools[65].slabFlags&slabFlagLengthPrefix, ShouldEqual, 0)
+		})
+
+		Convey("the reports should count the object lengths as live bytes", func() {
+			var live uint64
+			for value := range addrs {
+				if len(value) <= 8 {
+					live += uint64(len(value))
+				}
+			}
+			report, err := o.FragReportByObjSize(8)
+			So(err, ShouldBeNil)
+			So(report.LiveBytes, ShouldEqual, live)
+			So(report.WastedBytes, ShouldEqual, report.MappedBytes-live)
+
+			frag, err := o.FragStatsByObjSize(8)
+			So(err, ShouldBeNil)
+			So(frag, ShouldBeLessThan, float32(live)/float32(8*o.LenByObjSize(8))+0.01)
+		})
+
+		Convey("Get should return the objects with their exact length", func() {
+			for value, addr := range addrs {
+				obj, err := o.Get(addr)
+				So(err, ShouldBeNil)
+				So(string(obj), ShouldEqual, value)
+			}
+		})
+
+		Convey("Search should only find objects of the same length", func() {
+			for value, addr := range addrs {
+				found, ok := o.Search([]byte(value))
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, addr)
+			}
+			// the slot of "0-x" contains a zero byte behind the object
+			_, ok := o.Search([]byte("0-x\x00"))
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("deleting all objects should remove all pools", func() {
+			for _, addr := range addrs {
+				So(o.Delete(addr), ShouldBeNil)
+			}
+			So(o.slabPools, ShouldBeEmpty)
+			So(o.lookupTable, ShouldBeEmpty)
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+
+	Convey("When using the size classes in debug mode", t, func() {
+		c := NewConfig()
+		c.SizeClasses = DefaultSizeClasses()
+		c.Debug = true
+		o := NewObjectStore(c)
+
+		addr1, err := o.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		addr2, err := o.Add([]byte("abcdefghijk"))
+		So(err, ShouldBeNil)
+
+		Convey("the object addresses should be checked with the slot size", func() {
+			_, err = o.Get(addr1)
+			So(err, ShouldBeNil)
+			_, err = o.Get(addr1 + 1)
+			So(err, ShouldNotBeNil)
+			So(o.Delete(addr1), ShouldBeNil)
+			So(o.Delete(addr2), ShouldBeNil)
+		})
+	})
+}
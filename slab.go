@@ -3,67 +3,145 @@ package gos
 import (
 	"fmt"
 	"math"
-	"reflect"
+	"math/bits"
 	"strings"
 	"syscall"
 	"unsafe"
-
-	"github.com/willf/bitset"
 )
 
-// offsetOfBitSetData is the offset of the data property within the BitSet struct
-var offsetOfBitSetData = reflect.TypeOf(bitset.BitSet{}).Field(1).Offset
+const (
+	// slabMagic is written into the header of every slab, it allows us to
+	// detect whether an address really points at a slab
+	slabMagic uint32 = 0x676f7331 // "gos1"
+
+	// slabVersion is the version of the slab header layout. It must be
+	// incremented whenever the layout of the header changes
+	slabVersion uint8 = 2
+
+	// sizeOfSlabHeader is the size of the header at the beginning of each slab
+	sizeOfSlabHeader = unsafe.Sizeof(slab{})
+
+	// maxObjsPerSlab is the largest number of objects that a slab header
+	// can describe
+	maxObjsPerSlab = math.MaxUint32
+
+	// maxObjCountPerSlab is the largest number of objects of the slabs
+	// which are created by slab pools, with 255 byte objects such a slab
+	// has about 4GB
+	maxObjCountPerSlab = 1 << 24
+)
 
-// sizeOfBitSet is the size of the BitSet struct excluding the data that's used
-// by its internal byte slice
-const sizeOfBitSet = unsafe.Sizeof(bitset.BitSet{})
+const (
+	// slabFlagDebug marks slabs which are surrounded by guard pages and
+	// which poison the slots of deleted objects
+	slabFlagDebug uint16 = 1 << iota
+
+	// slabFlagFingerprints marks slabs which store a one byte fingerprint
+	// of each object between the bitmap and the object slots, so that
+	// searches only need to compare objects with a matching fingerprint
+	slabFlagFingerprints
+
+	// slabFlagLengthPrefix marks slabs of a size class, their object size
+	// is the largest object size of the class and each object slot starts
+	// with a byte that contains the length of the stored object
+	slabFlagLengthPrefix
+
+	// slabFlagExpiry marks slabs which store an expiry timestamp of each
+	// object between the optional fingerprints and the object slots
+	slabFlagExpiry
+
+	// slabFlagClock marks slabs which store a reference bit of each object
+	// after the optional expiry timestamps, it gets set when the object is
+	// accessed and is used by the CLOCK eviction of the cache mode
+	slabFlagClock
+
+	// slabFlagHugePages marks slabs which are aligned to the huge page size
+	// and backed by transparent huge pages, their mapping ends at the next
+	// huge page boundary
+	slabFlagHugePages
+)
 
-// slabs are actually much bigger than the slab struct. We only use it
-// to look at the first byte of each slab as uint8, because that's where
-// objSize is stored
+// poisonByte is written into the slots of deleted objects of debug slabs
+const poisonByte = 0xdd
+
+// pageSize is the size of the memory pages which get mmapped for slabs
+var pageSize = uintptr(syscall.Getpagesize())
+
+// slab is the header that sits at the beginning of each slab. Slabs are
+// actually much bigger than the slab struct, the header is directly followed
+// by the bitmap words which track the used object slots and then by the
+// object slots themselves.
+//
+// The layout of the header is:
+// byte 0:      object size
+// byte 1:      header version
+// bytes 2-3:   flags
+// bytes 4-7:   magic number
+// bytes 8-11:  number of object slots
+// bytes 12-15: number of used object slots
 type slab struct {
 	objSize uint8
+	version uint8
+	flags   uint16
+	magic   uint32
+	slots   uint32
+	live    uint32
 }
 
 // String creates a long multi-line string which illustrates the slab in a pretty
 // and human-readable format
 func (s *slab) String() string {
 	var b strings.Builder
-	bitSet := s.bitSet()
-	bitSetBytes := bitSet.Bytes()
-	bitSetLen := bitSet.Len()
-	objSize := uint(s.objSize)
+	bitmap := s.bitmap()
+	objCount := s.objCount()
 
 	fmt.Fprintf(&b, "-------------------------------\n")
-	fmt.Fprintf(&b, "Slab Addr: %d\n", uintptr(unsafe.Pointer(s)))
-	fmt.Fprintf(&b, "Object Size: %d\n", objSize)
-	fmt.Fprintf(&b, "Object Count: %d\n", s.objCount())
-	fmt.Fprintf(&b, "Objects Per Slab: %d\n", bitSetLen)
-
-	for i := 0; i < len(bitSetBytes); i++ {
-		fmt.Fprintf(&b, "bitSet[%d]: % 08b ", i, bitSetBytes[i])
-		fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "Slab Addr: %d\n", s.addr())
+	fmt.Fprintf(&b, "Header Version: %d\n", s.version)
+	fmt.Fprintf(&b, "Object Size: %d\n", s.objSize)
+	fmt.Fprintf(&b, "Object Count: %d\n", s.live)
+	fmt.Fprintf(&b, "Objects Per Slab: %d\n", objCount)
+
+	for i := range bitmap {
+		fmt.Fprintf(&b, "bitmap[%d]: %064b\n", i, bitmap[i])
 	}
 
-	for i := uint(0); i < bitSetLen; i++ {
+	for i := uint(0); i < objCount; i++ {
 		fmt.Fprintf(&b, "% 03d\n", s.getObjByIdx(i))
 	}
 	return b.String()
 }
 
-// bitSetWordsFor takes a length and calculates how many words (uint64)
-// of space the bitset will need to store that many objects
-// this is mostly copied from github.com/willf/bitset.wordsNeeded()
-func bitSetWordsFor(length uint) int {
-	cap := ^uint(0)
-	wordSize := uint(64)
-	log2WordSize := uint(6)
+// bitmapWordsFor takes a number of objects and calculates how many
+// words (uint64) the bitmap needs to track that many object slots
+func bitmapWordsFor(objCount uint) int {
+	return int((uint64(objCount) + 63) / 64)
+}
 
-	if length > (cap - wordSize + 1) {
-		return int(cap >> log2WordSize)
+// metadataLenFor returns the number of bytes which a slab with the given
+// number of objects and flags uses for the header, the bitmap and the
+// optional metadata. It is the offset at which the object slots start
+func metadataLenFor(objCount uint, flags uint16) uintptr {
+	res := sizeOfSlabHeader + uintptr(bitmapWordsFor(objCount)*8)
+	if flags&slabFlagFingerprints != 0 {
+		res += uintptr(fingerprintWordsFor(objCount) * 8)
 	}
+	if flags&slabFlagExpiry != 0 {
+		res += uintptr(objCount) * 8
+	}
+	if flags&slabFlagClock != 0 {
+		res += uintptr(bitmapWordsFor(objCount) * 8)
+	}
+	return res
+}
 
-	return int((length + (wordSize - 1)) >> log2WordSize)
+// slotSizeFor returns the number of bytes used by each object slot of a
+// slab with the given object size and flags
+func slotSizeFor(objSize uint8, flags uint16) uintptr {
+	if flags&slabFlagLengthPrefix != 0 {
+		return uintptr(objSize) + 1
+	}
+	return uintptr(objSize)
 }
 
 // newSlab initializes a new slab based on the given parameters. It can
@@ -72,32 +150,128 @@ func bitSetWordsFor(length uint) int {
 // second value is nil
 // On failure the second returned value is an error
 func newSlab(objSize uint8, objCount uint) (*slab, error) {
-	bitSetWords := bitSetWordsFor(objCount)
-
-	// 1 byte for the objSize, that's a uint8
-	// sizeOfBitSet is the BitSet, excluding the data used by its data slice
-	// bitSetDataLen is the data used by the BitSets data slice
-	// the object slots take up (object size * object count) bytes
-	totalLen := 1 + int(sizeOfBitSet) + (bitSetWords * 8) + int(objSize)*int(objCount)
-	data, err := syscall.Mmap(-1, 0, totalLen, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	return newSlabWithFlags(objSize, objCount, 0)
+}
+
+// newSlabWithFlags is like newSlab, but it additionally sets the given flags
+// in the header of the new slab
+func newSlabWithFlags(objSize uint8, objCount uint, flags uint16) (*slab, error) {
+	if objCount > maxObjsPerSlab {
+		return nil, fmt.Errorf("newSlab: object count %d exceeds the maximum of %d", objCount, uint(maxObjsPerSlab))
+	}
+
+	// the header is followed by the bitmap words, the optional metadata and
+	// then the object slots take up (slot size * object count) bytes
+	totalLen := metadataLenFor(objCount, flags) + slotSizeFor(objSize, flags)*uintptr(objCount)
+	addr, err := mapSlabMemory(totalLen, flags)
 	if err != nil {
 		return nil, err
 	}
 
-	// set the objSize property of the new slab
-	data[0] = byte(objSize)
+	// the mmapped memory is zeroed, so the bitmap and the live count
+	// are already initialized and we only need to fill in the header
+	s := slabFromSlabAddr(addr)
+	s.objSize = objSize
+	s.version = slabVersion
+	s.flags = flags
+	s.magic = slabMagic
+	s.slots = uint32(objCount)
+
+	return s, nil
+}
+
+// mapSlabMemory mmaps the memory for a slab of the given length and
+// returns the address at which the slab starts
+// Debug slabs are surrounded by inaccessible guard pages, their end is
+// aligned with the trailing guard page so that accesses past the last
+// object fault
+func mapSlabMemory(totalLen uintptr, flags uint16) (SlabAddr, error) {
+	if flags&slabFlagHugePages != 0 {
+		return mapHugePageMemory(totalLen)
+	}
+	if flags&slabFlagDebug == 0 {
+		data, err := syscall.Mmap(-1, 0, int(totalLen), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+		if err != nil {
+			return 0, err
+		}
+		return SlabAddr(unsafe.Pointer(&data[0])), nil
+	}
+
+	regionLen := roundUpToPage(totalLen)
+	data, err := syscall.Mmap(-1, 0, int(regionLen+2*pageSize), syscall.PROT_NONE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return 0, err
+	}
+	err = mprotect(data[pageSize:pageSize+regionLen], syscall.PROT_READ|syscall.PROT_WRITE)
+	if err != nil {
+		syscall.Munmap(data)
+		return 0, err
+	}
+
+	// the header must remain 8 byte aligned
+	offset := (regionLen - totalLen) &^ 7
+	return SlabAddr(unsafe.Pointer(&data[pageSize+offset])), nil
+}
 
-	// set the bitset's .length property
-	*(*uint)(unsafe.Pointer(&data[1])) = objCount
+// roundUpToPage rounds the given length up to a multiple of the page size
+func roundUpToPage(length uintptr) uintptr {
+	return (length + pageSize - 1) &^ (pageSize - 1)
+}
+
+// mappedLenFor returns the number of bytes which get mmapped for a slab of
+// the given length with the given flags, see mapSlabMemory. It includes the
+// guard pages of debug slabs and the rounding up to whole pages, or to whole
+// huge pages for huge page slabs
+func mappedLenFor(totalLen uintptr, flags uint16) uintptr {
+	if flags&slabFlagHugePages != 0 {
+		return roundUpToHugePage(totalLen)
+	}
+	if flags&slabFlagDebug != 0 {
+		return roundUpToPage(totalLen) + 2*pageSize
+	}
+	return roundUpToPage(totalLen)
+}
 
-	// initialize the bitset's .set by setting the slice's cap/len/data
-	bitSetDataSlice := (*reflect.SliceHeader)(unsafe.Pointer(&data[1+offsetOfBitSetData]))
-	bitSetDataSlice.Cap = bitSetWords
-	bitSetDataSlice.Len = bitSetWords
-	bitSetDataSlice.Data = uintptr(unsafe.Pointer(&data[1+sizeOfBitSet]))
+// mappedLength returns the number of bytes which have been mmapped for
+// this slab
+func (s *slab) mappedLength() uintptr {
+	return mappedLenFor(s.getTotalLength(), s.flags)
+}
 
-	// return the data byte slice converted to a slab pointer
-	return (*slab)(unsafe.Pointer(&data[0])), nil
+// region returns the page aligned memory area which contains this slab,
+// it doesn't include the guard pages of debug slabs
+func (s *slab) region() []byte {
+	start := s.addr() &^ (pageSize - 1)
+	end := roundUpToPage(s.addr() + s.getTotalLength())
+	return unsafe.Slice((*byte)(unsafe.Pointer(start)), end-start)
+}
+
+// mapping returns the whole memory area which has been mmapped for this slab
+// syscall.Munmap only accepts slices which have the same length as the one
+// that has been returned by syscall.Mmap. Huge page slabs aren't mmapped by
+// syscall.Mmap, see mapHugePageMemory
+func (s *slab) mapping() []byte {
+	if s.flags&slabFlagDebug == 0 {
+		return unsafe.Slice((*byte)(unsafe.Pointer(s)), s.getTotalLength())
+	}
+	region := s.region()
+	start := uintptr(unsafe.Pointer(&region[0])) - pageSize
+	return unsafe.Slice((*byte)(unsafe.Pointer(start)), uintptr(len(region))+2*pageSize)
+}
+
+// protect sets the protection of the memory area which contains this slab
+// to the given syscall.PROT_* flags
+func (s *slab) protect(prot int) error {
+	return mprotect(s.region(), prot)
+}
+
+// unmap unmaps all the memory of this slab, the slab must
+// not be accessed anymore after calling unmap
+func (s *slab) unmap() error {
+	if s.flags&slabFlagHugePages != 0 {
+		return munmapRaw(s.addr(), roundUpToHugePage(s.getTotalLength()))
+	}
+	return syscall.Munmap(s.mapping())
 }
 
 // addr returns this slabs' address as a SlabAddr type
@@ -105,25 +279,165 @@ func (s *slab) addr() SlabAddr {
 	return SlabAddr(unsafe.Pointer(s))
 }
 
-// bitSet returns this slabs' BitSet as a pointer
-func (s *slab) bitSet() *bitset.BitSet {
-	return (*bitset.BitSet)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) + 1))
+// valid returns true if the header of this slab has the expected magic
+// number and version
+func (s *slab) valid() bool {
+	return s.magic == slabMagic && s.version == slabVersion
+}
+
+// bitmap returns the words which track the used object slots of this slab.
+// The returned slice refers to the mmapped memory of the slab
+func (s *slab) bitmap() []uint64 {
+	return unsafe.Slice((*uint64)(unsafe.Pointer(s.addr()+sizeOfSlabHeader)), bitmapWordsFor(s.objCount()))
+}
+
+// isUsed returns true if the object slot at the given index is in use
+func (s *slab) isUsed(idx uint) bool {
+	return s.bitmap()[idx/64]&(1<<(idx%64)) != 0
+}
+
+// setUsed marks the object slot at the given index as used
+func (s *slab) setUsed(idx uint) {
+	s.bitmap()[idx/64] |= 1 << (idx % 64)
+}
+
+// setFree marks the object slot at the given index as free
+func (s *slab) setFree(idx uint) {
+	s.bitmap()[idx/64] &^= 1 << (idx % 64)
+}
+
+// nextFree returns the index of the first free object slot
+// The second value is false if there is no free slot left
+func (s *slab) nextFree() (uint, bool) {
+	objCount := s.objCount()
+	for i, word := range s.bitmap() {
+		if word == math.MaxUint64 {
+			continue
+		}
+		idx := uint(i)*64 + uint(bits.TrailingZeros64(^word))
+		return idx, idx < objCount
+	}
+	return 0, false
 }
 
 // objCount returns the max number of objects each slab can contain
 func (s *slab) objCount() uint {
-	return s.bitSet().Len()
+	return uint(s.slots)
+}
+
+// full returns true if all object slots of this slab are in use
+func (s *slab) full() bool {
+	return s.live == s.slots
+}
+
+// empty returns true if none of the object slots of this slab are in use
+func (s *slab) empty() bool {
+	return s.live == 0
+}
+
+// contains returns true if the given object address lies inside of the
+// object slots of this slab
+func (s *slab) contains(obj ObjAddr) bool {
+	return obj >= s.addr()+s.getDataOffset()+s.prefixLen() && obj < s.addr()+s.getTotalLength()
 }
 
 // getTotalLength returns the total size of this slab in bytes
 func (s *slab) getTotalLength() uintptr {
-	return s.getDataOffset() + uintptr(s.objSize)*uintptr(s.objCount())
+	return s.getDataOffset() + s.slotSize()*uintptr(s.objCount())
+}
+
+// slotSize returns the number of bytes used by each object slot
+func (s *slab) slotSize() uintptr {
+	return slotSizeFor(s.objSize, s.flags)
+}
+
+// prefixLen returns the number of bytes in front of the object in each
+// object slot, the object addresses point behind them
+func (s *slab) prefixLen() uintptr {
+	return s.slotSize() - uintptr(s.objSize)
+}
+
+// objLen returns the length of the object at the given address
+func (s *slab) objLen(obj ObjAddr) uint8 {
+	if s.flags&slabFlagLengthPrefix != 0 {
+		return *(*uint8)(unsafe.Pointer(obj - 1))
+	}
+	return s.objSize
+}
+
+// objAt returns the object at the given address as a byte slice of its
+// exact length
+func (s *slab) objAt(obj ObjAddr) []byte {
+	return objFromObjAddr(obj, s.objLen(obj))
 }
 
 // getDataOffset returns the offset at which the stored objects start
 func (s *slab) getDataOffset() uintptr {
-	// multiply the BitSet bytes by 8 because it returns a slice of uint64
-	return uintptr(1) + sizeOfBitSet + uintptr(len(s.bitSet().Bytes())*8)
+	return metadataLenFor(s.objCount(), s.flags)
+}
+
+// fingerprints returns the words which contain the fingerprints of the
+// objects, 8 fingerprints per word. It must only be called on slabs
+// with the slabFlagFingerprints flag
+func (s *slab) fingerprints() []uint64 {
+	offset := sizeOfSlabHeader + uintptr(bitmapWordsFor(s.objCount())*8)
+	return unsafe.Slice((*uint64)(unsafe.Pointer(s.addr()+offset)), fingerprintWordsFor(s.objCount()))
+}
+
+// fingerprintBytes returns the fingerprints of the objects, one byte per
+// object slot. It must only be called on slabs with the
+// slabFlagFingerprints flag
+func (s *slab) fingerprintBytes() []byte {
+	offset := sizeOfSlabHeader + uintptr(bitmapWordsFor(s.objCount())*8)
+	return unsafe.Slice((*byte)(unsafe.Pointer(s.addr()+offset)), s.objCount())
+}
+
+// expiries returns the expiry timestamps of the objects in Unix
+// nanoseconds, one per object slot. 0 means that the object doesn't
+// expire. It must only be called on slabs with the slabFlagExpiry flag
+func (s *slab) expiries() []int64 {
+	offset := metadataLenFor(s.objCount(), s.flags&^(slabFlagExpiry|slabFlagClock))
+	return unsafe.Slice((*int64)(unsafe.Pointer(s.addr()+offset)), s.objCount())
+}
+
+// findObj searches this slab for a live object which equals searching
+// fp is the fingerprint of searching, it is ignored if this slab doesn't
+// store fingerprints
+// When found it returns the object address and true, otherwise the second
+// returned value is false
+func (s *slab) findObj(searching []byte, fp byte) (ObjAddr, bool) {
+	if s.flags&slabFlagFingerprints != 0 {
+		// compare the fingerprints of 8 objects at a time and only
+		// compare the objects themselves if the fingerprint matches
+		for i, word := range s.fingerprints() {
+			matches := matchBytes(word, fp)
+			for matches != 0 {
+				var offset uint
+				offset, matches = nextMatch(matches)
+				idx := uint(i)*8 + offset
+				if !s.isUsed(idx) {
+					continue
+				}
+				if obj := s.getObjByIdx(idx); len(obj) == len(searching) && equalObj(obj, searching) {
+					return s.addr() + s.getObjOffset(idx), true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	// iterate over the bitmap a word at a time, so we skip 64 free
+	// slots at once and jump directly to the used ones
+	for i, word := range s.bitmap() {
+		for word != 0 {
+			idx := uint(i)*64 + uint(bits.TrailingZeros64(word))
+			word &= word - 1
+			if obj := s.getObjByIdx(idx); len(obj) == len(searching) && equalObj(obj, searching) {
+				return s.addr() + s.getObjOffset(idx), true
+			}
+		}
+	}
+	return 0, false
 }
 
 // getObjOffset returns the offset at which the object
@@ -133,7 +447,7 @@ func (s *slab) getObjOffset(idx uint) uintptr {
 	dataOffset := s.getDataOffset()
 
 	// offset where the object is within the data range
-	objectOffset := uintptr(s.objSize) * uintptr(idx)
+	objectOffset := s.slotSize()*uintptr(idx) + s.prefixLen()
 
 	return dataOffset + objectOffset
 }
@@ -141,14 +455,11 @@ func (s *slab) getObjOffset(idx uint) uintptr {
 // getObjIdx takes an object address and returns the object index
 // within this slice
 func (s *slab) getObjIdx(obj ObjAddr) uint {
-	// offset where the slices object data begins
-	dataOffset := uintptr(1) + sizeOfBitSet + uintptr(len(s.bitSet().Bytes())*8)
-
 	// offset where the object is within the data range
-	objectOffset := obj - dataOffset - uintptr(unsafe.Pointer(s))
+	objectOffset := obj - s.getDataOffset() - s.addr()
 
-	// calculate index based on object offset and object size
-	return uint(objectOffset / uintptr(s.objSize))
+	// calculate index based on object offset and slot size
+	return uint(objectOffset / s.slotSize())
 }
 
 // addObj takes an object and adds it to this slice if there is
@@ -158,31 +469,50 @@ func (s *slab) getObjIdx(obj ObjAddr) uint {
 // the slab is full, the third value indicates success
 // On failure the third return value is false, otherwise it's true
 func (s *slab) addObj(obj []byte, idx uint) (ObjAddr, bool, bool) {
-	offset := s.getObjOffset(idx)
+	if idx >= s.objCount() || s.isUsed(idx) {
+		return 0, s.full(), false
+	}
 
 	// objAddr is used as the unique identifier of the newly created object
-	objAddr := uintptr(unsafe.Pointer(s)) + offset
+	objAddr := s.writeObj(obj, idx)
+
+	// set the according object slot as used
+	s.setUsed(idx)
+	s.live++
+
+	return objAddr, s.full(), true
+}
+
+// writeObj writes the given object into the object slot at the given
+// index, it doesn't change whether the slot is in use
+// It returns the address of the written object
+func (s *slab) writeObj(obj []byte, idx uint) ObjAddr {
+	objAddr := s.addr() + s.getObjOffset(idx)
 
 	len := uintptr(len(obj))
-	src := (*reflect.SliceHeader)(unsafe.Pointer(&obj)).Data
+	src := unsafe.Pointer(&obj[0])
 
 	var i uintptr
 	// if length is more than 8 we simply copy as uint64 one-by-one in 8byte chunks
 	for ; i+8 <= len; i = i + 8 {
-		*(*uint64)(unsafe.Pointer(objAddr + i)) = *(*uint64)(unsafe.Pointer(src + i))
+		*(*uint64)(unsafe.Pointer(objAddr + i)) = *(*uint64)(unsafe.Add(src, i))
 	}
 
-	// if the length is not divisible by 8 we need to copy the left over data
-	remainder := len % 8
-	if remainder != 0 {
-		*((*uint64)(unsafe.Pointer(objAddr + i))) |= (*((*uint64)(unsafe.Pointer(src + i))) & (math.MaxUint64 >> ((8 - remainder) * 8)))
+	// if the length is not divisible by 8 we copy the left over data byte by
+	// byte, so we neither read past the end of obj nor write past the slot
+	for ; i < len; i++ {
+		*(*byte)(unsafe.Pointer(objAddr + i)) = *(*byte)(unsafe.Add(src, i))
 	}
 
-	// set the according object slot as used
-	bitSet := s.bitSet()
-	bitSet.Set(idx)
+	if s.flags&slabFlagLengthPrefix != 0 {
+		*(*uint8)(unsafe.Pointer(objAddr - 1)) = uint8(len)
+	}
 
-	return objAddr, bitSet.All(), true
+	if s.flags&slabFlagFingerprints != 0 {
+		s.fingerprintBytes()[idx] = fingerprintOf(obj)
+	}
+
+	return objAddr
 }
 
 // delete deletes the object at the given object address
@@ -190,12 +520,23 @@ func (s *slab) addObj(obj []byte, idx uint) (ObjAddr, bool, bool) {
 // on true it is empty, otherwise there is still some data in it
 func (s *slab) delete(obj ObjAddr) bool {
 	idx := s.getObjIdx(obj)
-	bitSet := s.bitSet()
-	bitSet.Clear(idx)
-	return bitSet.None()
+	if s.isUsed(idx) {
+		s.setFree(idx)
+		s.live--
+		if s.flags&slabFlagExpiry != 0 {
+			s.expiries()[idx] = 0
+		}
+		if s.flags&slabFlagClock != 0 {
+			s.references()[idx/64] &^= 1 << (idx % 64)
+		}
+		if s.flags&slabFlagDebug != 0 {
+			poison(s.getObjByIdx(idx))
+		}
+	}
+	return s.empty()
 }
 
 // getObjByIdx returns the object at the given index as a byte slice
 func (s *slab) getObjByIdx(idx uint) []byte {
-	return objFromObjAddr(uintptr(unsafe.Pointer(s))+s.getObjOffset(idx), s.objSize)
+	return s.objAt(s.addr() + s.getObjOffset(idx))
 }
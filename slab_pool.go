@@ -1,12 +1,10 @@
 package gos
 
 import (
+	"context"
 	"fmt"
-	"math"
-	"reflect"
-	"runtime"
+	"math/bits"
 	"sort"
-	"sync"
 	"sync/atomic"
 	"syscall"
 	"unsafe"
@@ -20,6 +18,63 @@ type slabPool struct {
 	slabs     []*slab
 	objSize   uint8
 	freeSlabs bitset.BitSet
+
+	// objects is the number of objects stored in all slabs of this pool
+	// capacity is the number of object slots in all slabs of this pool
+	// mapped is the number of bytes mmapped by all slabs of this pool
+	objects  uint64
+	capacity uint64
+	mapped   uint64
+
+	// mappedRegions is the number of bytes of the memory regions which
+	// have been mmapped for the slabs of this pool. Unlike mapped it
+	// includes the guard pages of debug slabs and the rounding up to whole
+	// pages or huge pages, MaxBytes gets checked against it
+	mappedRegions uint64
+
+	// stats is shared with the object store, it is nil if
+	// the stats collection is disabled
+	stats *opStats
+
+	// observer gets notified about created and destroyed slabs,
+	// it is nil unless the config has an Observer
+	observer Observer
+
+	// slabFlags are set in the header of every new slab
+	slabFlags uint16
+
+	// maxObjsPerSlab and maxSlabBytes limit the size of new slabs,
+	// 0 means that there is no limit. They are copied from the config
+	// and updated by SetSlabLimits
+	maxObjsPerSlab uint
+	maxSlabBytes   uint64
+
+	// placement selects the slab which gets the added objects
+	placement Placement
+
+	// releaseEmptyPages makes delete release the pages of the deleted
+	// object once they contain no live objects
+	releaseEmptyPages bool
+
+	// hugePages makes the pool back large slabs by huge pages and fill
+	// their huge pages with object slots
+	hugePages bool
+
+	// quarantine is shared with the object store, it is only set in
+	// debug mode. Emptied slabs get quarantined instead of unmapped
+	quarantine *quarantine
+
+	// frozen is true if the slabs of this pool are mprotected read-only
+	frozen bool
+
+	// filter is nil unless the cuckoo filter is enabled, it contains
+	// all objects of this pool
+	filter *cuckooFilter
+
+	// clockSlab and clockIdx are the position of the clock hand, which
+	// picks the objects that get evicted in the cache mode
+	clockSlab int
+	clockIdx  uint
 }
 
 // NewSlabPool initializes a new slab pool and returns a pointer to it
@@ -40,70 +95,131 @@ func (s *slabPool) fragStats() float32 {
 	var total float32
 
 	// iterate over all slabs in the pool
-	// get fragmentation percent
+	// get fragmentation percent, in the pools of size classes the objects
+	// may not fill their slots
 	for _, sl := range s.slabs {
-		total += float32(sl.bitSet().Count()) / float32(sl.objCount())
+		total += float32(sl.liveBytes()) / float32(uint64(sl.objCount())*uint64(sl.objSize))
 	}
 
 	return total / length
 }
 
+func (s *slabPool) countStats() CountStat {
+	return CountStat{
+		ObjSize:  s.objSize,
+		Objects:  s.objects,
+		Capacity: s.capacity,
+		Free:     s.capacity - s.objects,
+		Slabs:    len(s.slabs),
+	}
+}
+
 func (s *slabPool) memStats() uint64 {
-	length := uint64(len(s.slabs))
+	return s.mapped
+}
 
-	if length < 1 {
-		return 0
+// objCountFor returns the number of objects of the slab which gets created
+// when all slabs of a pool are full and the pool contains live objects
+// objCount is floor(<base objects per slab> + <live objects> * (<growth factor> - 1))
+// As long as no objects get deleted this lets the slabs grow by the growth
+// factor, for example:
+// base objects per slab: 10
+// growth factor: 1.3
+// slab 0: 10
+// slab 1: 13
+// slab 2: 16
+// slab 3: 21
+// slab 4: 28
+// slab 5: 36
+// slab 6: 47
+// Unlike a growth based on the number of slabs this keeps the slabs small
+// after most objects of a pool have been deleted, even if some slabs are
+// still pinned by leftover objects
+// The result is clamped to the range from 1 to maxObjCountPerSlab, so a
+// degenerate configuration can neither create slabs without object slots
+// nor overflow the object count
+func objCountFor(baseObjsPerSlab uint8, growthFactor float64, live uint64) uint {
+	objCount := float64(baseObjsPerSlab) + float64(live)*(growthFactor-1)
+	if !(objCount >= 1) {
+		// this also catches NaN
+		return 1
 	}
+	if objCount > maxObjCountPerSlab {
+		return maxObjCountPerSlab
+	}
+	return uint(objCount)
+}
 
-	// add MMapped slab usage for the pool
-	slabLength := uint64(s.slabs[0].getTotalLength())
-	return length * slabLength
+// slabLenFor returns the number of bytes of a slab with the given object
+// size, object count and flags, excluding the guard pages of debug mode
+func slabLenFor(objSize uint8, objCount uint, flags uint16) uintptr {
+	return metadataLenFor(objCount, flags) + slotSizeFor(objSize, flags)*uintptr(objCount)
+}
+
+// capObjCount limits the given object count to maxObjs and to the number
+// of objects which fit into a slab of maxBytes, a limit of 0 is ignored
+// A slab always gets at least one object slot, even if it exceeds maxBytes
+func capObjCount(objCount, maxObjs uint, maxBytes uint64, objSize uint8, flags uint16) uint {
+	if maxObjs > 0 && objCount > maxObjs {
+		objCount = maxObjs
+	}
+	if maxBytes > 0 && uint64(slabLenFor(objSize, objCount, flags)) > maxBytes {
+		// the slab length grows with the object count, so we search for
+		// the largest count which fits
+		objCount = uint(sort.Search(int(objCount), func(n int) bool {
+			return uint64(slabLenFor(objSize, uint(n+1), flags)) > maxBytes
+		}))
+		if objCount == 0 {
+			objCount = 1
+		}
+	}
+	return objCount
+}
+
+// nextObjCount returns the number of objects of the slab which this pool
+// adds once all of its slabs are full
+func (s *slabPool) nextObjCount(baseObjsPerSlab uint8, growthFactor float64) uint {
+	objCount := objCountFor(baseObjsPerSlab, growthFactor, s.objects)
+	if s.hugePages {
+		objCount = packHugePages(objCount, s.objSize, s.slabFlags)
+	}
+	return capObjCount(objCount, s.maxObjsPerSlab, s.maxSlabBytes, s.objSize, s.slabFlags)
 }
 
 // add adds an object to the pool
 // It will try to find a slab that has a free object slot to avoid
-// unnecessary allocations. If it can't find a free slot, it will add a
-// slab and then use that one
+// unnecessary allocations, see Placement. If it can't find a free slot, it
+// will add a slab and then use that one
 // The first return value is the ObjAddr of the added object
 // The second value is the slab address if the call created a new slab
 // If no new slab has been created, then the second value is 0
 // The third value is nil if there was no error, otherwise it is the error
 func (s *slabPool) add(obj []byte, baseObjsPerSlab uint8, growthFactor float64) (ObjAddr, SlabAddr, error) {
+	return s.addWithExpiry(obj, baseObjsPerSlab, growthFactor, 0)
+}
+
+// addWithExpiry is like add, but it also stores the given expiry timestamp
+// in Unix nanoseconds if the slabs of this pool store expiry timestamps
+func (s *slabPool) addWithExpiry(obj []byte, baseObjsPerSlab uint8, growthFactor float64, expiry int64) (ObjAddr, SlabAddr, error) {
 	var currentSlab *slab
 	var objIdx uint
 
-	slabCount := uint(len(s.slabs))
-
-	found := false
 	exists := false
-	var slabIdx uint
-	if slabCount > 0 {
-		slabIdx, found = s.freeSlabs.NextClear(0)
-
-		if found {
-			currentSlab = s.slabs[slabIdx]
-			objIdx, exists = currentSlab.bitSet().NextClear(0)
-			if !exists {
-				return 0, 0, fmt.Errorf("Add: Failed to add object into slab")
-			}
+	slabIdx, found := s.pickSlab()
+	if found {
+		currentSlab = s.slabs[slabIdx]
+		objIdx, exists = currentSlab.nextFree()
+		if !exists {
+			return 0, 0, fmt.Errorf("Add: Failed to add object into slab")
+		}
+		if err := s.thaw(currentSlab); err != nil {
+			return 0, 0, err
 		}
 	}
 
 	var newSlab SlabAddr
 	if !found {
-		// objCount is floor(<base objects per slab> * <growth Factor> ^ <number of slab>)
-		// For Example:
-		// base objects per slab: 10
-		// growth factor: 1.3
-		// slab 0: 10
-		// slab 1: 13
-		// slab 2: 16
-		// slab 3: 21
-		// slab 4: 28
-		// slab 5: 37
-		// slab 6: 48
-		objCount := uint(float64(baseObjsPerSlab) * math.Pow(growthFactor, float64(slabCount)))
-		newIdx, err := s.addSlab(objCount)
+		newIdx, err := s.addSlab(s.nextObjCount(baseObjsPerSlab, growthFactor))
 		if err != nil {
 			return 0, 0, err
 		}
@@ -119,12 +235,17 @@ func (s *slabPool) add(obj []byte, baseObjsPerSlab uint8, growthFactor float64)
 		// whether this slab has space or not
 		return 0, 0, fmt.Errorf("Add: Failed to add object into slab")
 	}
+	s.objects++
+	s.filterAdd(obj)
+	if s.slabFlags&slabFlagExpiry != 0 {
+		currentSlab.expiries()[objIdx] = expiry
+	}
 	if full {
 		// mark that slab as full so nothing more gets added
 		s.freeSlabs.Set(slabIdx)
 	}
 
-	return objAddr, newSlab, nil
+	return objAddr, newSlab, s.refreeze(currentSlab)
 }
 
 // delete takes an ObjAddr and a SlabAddr, it will delete the according
@@ -132,9 +253,24 @@ func (s *slabPool) add(obj []byte, baseObjsPerSlab uint8, growthFactor float64)
 // properties.
 // On success it returns true and nil if the slab was also deleted.
 // On success it returns false and nil if the slab was not also deleted.
-// On error it returns false and an error.
+// On error it returns false and an error, this is also the case if the
+// object has already been deleted
 func (s *slabPool) delete(obj ObjAddr, slabAddr SlabAddr) (bool, error) {
-	empty := slabFromSlabAddr(slabAddr).delete(obj)
+	currentSlab := slabFromSlabAddr(slabAddr)
+	// the filter may contain the same fingerprint for another object, so
+	// it must only be removed for live objects
+	if !currentSlab.isUsed(currentSlab.getObjIdx(obj)) {
+		return false, fmt.Errorf("Delete: object 0x%x is not live", obj)
+	}
+	if err := s.thaw(currentSlab); err != nil {
+		return false, err
+	}
+	if s.filter != nil {
+		s.filter.remove(objHash(currentSlab.objAt(obj)))
+	}
+	live := currentSlab.live
+	empty := currentSlab.delete(obj)
+	s.objects -= uint64(live - currentSlab.live)
 
 	if empty {
 		return s.deleteSlab(slabAddr)
@@ -146,7 +282,54 @@ func (s *slabPool) delete(obj ObjAddr, slabAddr SlabAddr) (bool, error) {
 	slabIdx := s.findSlabByAddr(slabAddr)
 	s.freeSlabs.Clear(uint(slabIdx))
 
-	return false, nil
+	// releasing the pages is best-effort, the object has already been
+	// deleted, so if madvise fails the pages simply stay resident
+	if s.releaseEmptyPages {
+		currentSlab.releaseSlot(currentSlab.getObjIdx(obj))
+	}
+
+	return false, s.refreeze(currentSlab)
+}
+
+// freeze mprotects all slabs of this pool read-only, any write to them
+// faults until the pool gets unfrozen again
+func (s *slabPool) freeze() error {
+	for _, sl := range s.slabs {
+		if err := sl.protect(syscall.PROT_READ); err != nil {
+			return err
+		}
+	}
+	s.frozen = true
+	return nil
+}
+
+// unfreeze makes all slabs of this pool writable again
+func (s *slabPool) unfreeze() error {
+	for _, sl := range s.slabs {
+		if err := sl.protect(syscall.PROT_READ | syscall.PROT_WRITE); err != nil {
+			return err
+		}
+	}
+	s.frozen = false
+	return nil
+}
+
+// thaw makes the given slab writable if this pool is frozen, it must
+// be called before modifying a slab
+func (s *slabPool) thaw(sl *slab) error {
+	if !s.frozen {
+		return nil
+	}
+	return sl.protect(syscall.PROT_READ | syscall.PROT_WRITE)
+}
+
+// refreeze makes the given slab read-only again if this pool is frozen,
+// it must be called after a slab has been modified
+func (s *slabPool) refreeze(sl *slab) error {
+	if !s.frozen {
+		return nil
+	}
+	return sl.protect(syscall.PROT_READ)
 }
 
 // findSlabByObjAddr takes an object address or slab address and then
@@ -159,11 +342,21 @@ func (s *slabPool) findSlabByAddr(obj uintptr) int {
 	return sort.Search(len(s.slabs), func(i int) bool { return s.slabs[i].addr() <= obj })
 }
 
+// flagsForSlab returns the flags of a new slab of this pool with the given
+// object count, large slabs get backed by huge pages if they are enabled
+func (s *slabPool) flagsForSlab(objCount uint) uint16 {
+	flags := s.slabFlags
+	if s.hugePages && wantsHugePages(slabLenFor(s.objSize, objCount, flags)) {
+		flags |= slabFlagHugePages
+	}
+	return flags
+}
+
 // addSlab adds another slab to the pool and initalizes the related structs
 // on success the first returned value is the index of the new slab
 // on failure the second returned value is the error message
 func (s *slabPool) addSlab(objCount uint) (int, error) {
-	addedSlab, err := newSlab(s.objSize, objCount)
+	addedSlab, err := newSlabWithFlags(s.objSize, objCount, s.flagsForSlab(objCount))
 	if err != nil {
 		return 0, err
 	}
@@ -178,6 +371,13 @@ func (s *slabPool) addSlab(objCount uint) (int, error) {
 	s.slabs[insertAt] = addedSlab
 
 	s.freeSlabs.InsertAt(uint(insertAt))
+	s.capacity += uint64(objCount)
+	s.mapped += uint64(addedSlab.getTotalLength())
+	s.mappedRegions += uint64(addedSlab.mappedLength())
+	s.stats.slabMapped(addedSlab.getTotalLength())
+	if s.observer != nil {
+		s.observer.OnSlabCreated(s.objSize, newSlabAddr, addedSlab.getTotalLength())
+	}
 
 	return insertAt, nil
 }
@@ -198,22 +398,29 @@ func (s *slabPool) deleteSlab(slabAddr SlabAddr) (bool, error) {
 	s.slabs = s.slabs[:len(s.slabs)-1]
 
 	totalLen := int(currentSlab.getTotalLength())
-
-	// unmap the slab's memory
-	// to do so we need to built a byte slice that refers to the whole
-	// slab as its underlying memory area
-	var toDelete []byte
-	sliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&toDelete))
-	sliceHeader.Data = uintptr(unsafe.Pointer(currentSlab))
-	sliceHeader.Len = totalLen
-	sliceHeader.Cap = sliceHeader.Len
-
-	err := syscall.Munmap(toDelete)
+	regionLen := currentSlab.mappedLength()
+	objCount := currentSlab.objCount()
+
+	// unmap the slab's memory, in debug mode it only gets
+	// quarantined to catch accesses to deleted objects
+	var err error
+	if s.quarantine != nil {
+		err = s.quarantine.add(currentSlab)
+	} else {
+		err = currentSlab.unmap()
+	}
 	if err != nil {
 		return false, err
 	}
 
 	s.freeSlabs.DeleteAt(uint(slabIdx))
+	s.capacity -= uint64(objCount)
+	s.mapped -= uint64(totalLen)
+	s.mappedRegions -= uint64(regionLen)
+	s.stats.slabUnmapped(uintptr(totalLen))
+	if s.observer != nil {
+		s.observer.OnSlabDestroyed(s.objSize, slabAddr, uintptr(totalLen))
+	}
 
 	return true, nil
 }
@@ -226,60 +433,47 @@ func (s *slabPool) deleteSlab(slabAddr SlabAddr) (bool, error) {
 // scanning through all the data without any index,
 // only use it when there's no other choice
 func (s *slabPool) search(searching []byte) (ObjAddr, bool) {
-	wg := sync.WaitGroup{}
-	objSize := int(s.objSize)
+	result, found, _ := s.searchContext(context.Background(), searching)
+	return result, found
+}
+
+// searchContext is like search, but it stops early once ctx is done
+// In that case the returned error is ctx.Err()
+func (s *slabPool) searchContext(ctx context.Context, searching []byte) (ObjAddr, bool, error) {
 	var result uintptr
 
-	goMaxProcs := runtime.GOMAXPROCS(0)
-	wg.Add(goMaxProcs)
-	slabCount := len(s.slabs)
+	// the filter rules out most searches for absent objects
+	if s.filter != nil && !s.filter.contains(objHash(searching)) {
+		atomic.AddUint64(&s.filter.negatives, 1)
+		return 0, false, nil
+	}
 
-	slabIdxChan := make(chan uint, slabCount)
+	var fp byte
+	if s.slabFlags&slabFlagFingerprints != 0 {
+		fp = fingerprintOf(searching)
+	}
 
-	go func() {
-		for idx := range s.slabs {
-			slabIdxChan <- uint(idx)
+	err := scanSlabs(ctx, s.slabs, func(currentSlab *slab) bool {
+		// if result has been found by another thread we can stop
+		if atomic.LoadUintptr(&result) > 0 {
+			return false
 		}
-		close(slabIdxChan)
-	}()
-
-	for i := 0; i < goMaxProcs; i++ {
-		go func() {
-			defer wg.Done()
-
-			for slabIdx := range slabIdxChan {
-				currentSlab := s.slabs[slabIdx]
-				objCount := currentSlab.objCount()
-
-			OBJECT:
-				for objID := uint(0); objID < objCount; objID++ {
-					if currentSlab.bitSet().Test(objID) {
-						obj := currentSlab.getObjByIdx(objID)
-						for j := 0; j < objSize; j++ {
-							if obj[j] != searching[j] {
-								continue OBJECT
-							}
-						}
-
-						// found it, store the result atomically
-						atomic.StoreUintptr(&result, objAddrFromObj(obj))
-						return
-					}
-				}
+		if objAddr, found := currentSlab.findObj(searching, fp); found {
+			s.touch(currentSlab, objAddr)
+			// found it, store the result atomically
+			atomic.StoreUintptr(&result, objAddr)
+			return false
+		}
+		return true
+	})
 
-				// if result has been found by another thread we can exit this thread
-				if atomic.LoadUintptr(&result) > 0 {
-					//fmt.Println("exiting routine because result was found")
-					return
-				}
-			}
-			//fmt.Println("exiting routine because we've done all iterations")
-		}()
+	if result > 0 {
+		return result, true, nil
 	}
-
-	wg.Wait()
-
-	return result, result > 0
+	if s.filter != nil && err == nil {
+		atomic.AddUint64(&s.filter.falsePositives, 1)
+	}
+	return 0, false, err
 }
 
 // searchBatched searches for a batch of search objects.
@@ -291,61 +485,109 @@ func (s *slabPool) search(searching []byte) (ObjAddr, bool) {
 // If a searched object has not been found, then the value in the returned
 // slice is 0 at the index of the searched object.
 func (s *slabPool) searchBatched(searching [][]byte) []ObjAddr {
-	wg := sync.WaitGroup{}
-
 	// preallocate the result set that will be returned
 	resultSet := make([]ObjAddr, len(searching))
 	resultsLeft := int32(len(searching))
-	objSize := int(s.objSize)
-
-	wg.Add(len(s.slabs))
-	for i := range s.slabs {
-
-		// every slab gets a go routine which searches for all searched objects
-		go func(currentSlab *slab) {
-			defer wg.Done()
-			objCount := currentSlab.objCount()
-
-			// iterate over objects in slab
-			for j := uint(0); j < objCount; j++ {
 
-				// if the current object slot is in use, then we compare its
-				// value to the searched objects
-				if currentSlab.bitSet().Test(j) {
-					storedObj := currentSlab.getObjByIdx(j)
-
-					// compare all searched objects to the stored object
-				SEARCH:
-					for k, searchedObj := range searching {
-						for l := 0; l < objSize; l++ {
-							if storedObj[l] != searchedObj[l] {
-								continue SEARCH
-							}
+	// the objects which are ruled out by the filter don't get compared
+	var candidates []bool
+	if s.filter != nil {
+		candidates = make([]bool, len(searching))
+		resultsLeft = 0
+		for i, searchedObj := range searching {
+			candidates[i] = s.filter.contains(objHash(searchedObj))
+			if candidates[i] {
+				resultsLeft++
+			} else {
+				atomic.AddUint64(&s.filter.negatives, 1)
+			}
+		}
+		if resultsLeft == 0 {
+			return resultSet
+		}
+	}
 
-						}
+	// with fingerprints we only compare the objects whose
+	// fingerprints match the ones of the searched objects
+	var fps []byte
+	if s.slabFlags&slabFlagFingerprints != 0 {
+		fps = make([]byte, len(searching))
+		for i, searchedObj := range searching {
+			fps[i] = fingerprintOf(searchedObj)
+		}
+	}
 
-						// found one search term, store it in the right location atomically
-						atomic.StoreUintptr(&resultSet[k], objAddrFromObj(storedObj))
+	// the slabs get scanned by the scan workers, each one searches for all searched objects
+	scanSlabs(context.Background(), s.slabs, func(currentSlab *slab) bool {
+		var storedFps []byte
+		if fps != nil {
+			storedFps = currentSlab.fingerprintBytes()
+		}
 
-						// decrease number of searches left by one
-						atomic.AddInt32(&resultsLeft, -1)
+		// iterate over the used object slots in slab, one bitmap word at a time
+		for i, word := range currentSlab.bitmap() {
+			for word != 0 {
+				j := uint(i)*64 + uint(bits.TrailingZeros64(word))
+				word &= word - 1
+				storedObj := currentSlab.getObjByIdx(j)
+
+				// compare all searched objects to the stored object
+				for k, searchedObj := range searching {
+					if candidates != nil && !candidates[k] {
+						continue
 					}
-				}
+					if fps != nil && fps[k] != storedFps[j] {
+						continue
+					}
+					if len(storedObj) != len(searchedObj) || !equalObj(storedObj, searchedObj) {
+						continue
+					}
+
+					// found one search term, store it in the right location atomically
+					atomic.StoreUintptr(&resultSet[k], objAddrFromObj(storedObj))
 
-				if atomic.LoadInt32(&resultsLeft) == 0 {
-					// all search terms have been found, exit routine
-					return
+					// decrease number of searches left by one
+					atomic.AddInt32(&resultsLeft, -1)
 				}
 			}
-		}(s.slabs[i])
-	}
 
-	wg.Wait()
+			if atomic.LoadInt32(&resultsLeft) <= 0 {
+				// all search terms have been found, stop scanning
+				return false
+			}
+		}
+		return true
+	})
 
 	return resultSet
 }
 
+// replace overwrites the object at the given address with the given
+// value, which must fit into the object slot
+// The first returned value is true if the object has been overwritten,
+// which is also the case if protecting the slab again has failed
+// It returns an error if the object isn't live
+func (s *slabPool) replace(obj ObjAddr, slabAddr SlabAddr, value []byte) (bool, error) {
+	currentSlab := slabFromSlabAddr(slabAddr)
+	if !currentSlab.isUsed(currentSlab.getObjIdx(obj)) {
+		return false, fmt.Errorf("Replace: object 0x%x is not live", obj)
+	}
+	if err := s.thaw(currentSlab); err != nil {
+		return false, err
+	}
+	if s.filter != nil {
+		s.filter.remove(objHash(currentSlab.objAt(obj)))
+		s.filter.insert(objHash(value))
+	}
+	currentSlab.writeObj(value, currentSlab.getObjIdx(obj))
+
+	return true, s.refreeze(currentSlab)
+}
+
 // get returns an object of the given object address as a byte slice
 func (s *slabPool) get(obj ObjAddr) []byte {
+	if s.slabFlags&slabFlagLengthPrefix != 0 {
+		return objFromObjAddr(obj, *(*uint8)(unsafe.Pointer(obj - 1)))
+	}
 	return objFromObjAddr(obj, s.objSize)
 }
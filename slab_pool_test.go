@@ -1,6 +1,7 @@
 package gos
 
 import (
+	"bytes"
 	"fmt"
 	"math"
 	"math/rand"
@@ -212,6 +213,36 @@ func TestBatchSearchingObjects(t *testing.T) {
 	})
 }
 
+func TestSearchingObjectsWithFingerprints(t *testing.T) {
+	objSize := uint8(5)
+	objsPerSlab := uint(10)
+	expectedSlabs := uint(100)
+	sp := NewSlabPool(objSize)
+	sp.slabFlags = slabFlagFingerprints
+
+	Convey(fmt.Sprintf("When adding %d objects to a pool with fingerprints", objsPerSlab*expectedSlabs), t, func() {
+		for i := uint(0); i < objsPerSlab*expectedSlabs; i++ {
+			_, _, err := sp.add([]byte(fmt.Sprintf("%05d", i)), uint8(objsPerSlab), 1)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("we should be able to find them with search and searchBatched", func() {
+			for _, searchObject := range []string{"00325", "00999", "00000", "00010"} {
+				addr, success := sp.search([]byte(searchObject))
+				So(success, ShouldBeTrue)
+				So(string(sp.get(addr)), ShouldEqual, searchObject)
+			}
+			_, success := sp.search([]byte("abcde"))
+			So(success, ShouldBeFalse)
+
+			searchResults := sp.searchBatched([][]byte{[]byte("00100"), []byte("abcde"), []byte("00999")})
+			So(string(sp.get(searchResults[0])), ShouldEqual, "00100")
+			So(searchResults[1], ShouldEqual, 0)
+			So(string(sp.get(searchResults[2])), ShouldEqual, "00999")
+		})
+	})
+}
+
 func TestFragmentedSlabPoolSizes(t *testing.T) {
 	Convey("When adding 63 object with base objs per slab 1 and growth factor 2", t, func() {
 		// config for objCounts per slab: 1, 2, 4, 8, 16
@@ -268,21 +299,80 @@ func TestFragmentedSlabPoolSizes(t *testing.T) {
 
 				So(len(pool.slabs), ShouldEqual, 1)
 
-				Convey("When adding one more object, a new slab with the size of the 2nd slab should get created", func() {
+				Convey("When adding one more object, a new slab sized by the live objects should get created", func() {
 					objAddr, slabAddr, err := pool.add([]byte(fmt.Sprintf("%10d", 0)), baseObjsPerSlab, growthFactor)
 					So(err, ShouldBeNil)
 					So(objAddr, ShouldNotEqual, 0)
 					So(slabAddr, ShouldNotEqual, 0)
 					So(len(pool.slabs), ShouldEqual, 2)
 
-					// with baseObjsPerSlab = 1 & growthFactor = 2 the second slab should have the size of 2 objects
-					So(pool.slabs[pool.findSlabByAddr(objAddr)].objCount(), ShouldEqual, 2)
+					// with baseObjsPerSlab = 1 & growthFactor = 2 and 32 live objects
+					// the second slab should have the size of 1 + 32 objects
+					So(pool.slabs[pool.findSlabByAddr(objAddr)].objCount(), ShouldEqual, 33)
 				})
 			})
 		})
 	})
 }
 
+func TestSlabSizesAfterChurn(t *testing.T) {
+	Convey("When most objects of a grown pool got deleted", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(10), WithGrowthFactor(2))
+		So(err, ShouldBeNil)
+
+		// creates 10 slabs with 10, 20, 40, ... 5120 objects
+		var objAddrs []ObjAddr
+		for i := 0; i < 10230; i++ {
+			objAddr, err := o.Add([]byte(fmt.Sprintf("%10d", i)))
+			So(err, ShouldBeNil)
+			objAddrs = append(objAddrs, objAddr)
+		}
+		pool := o.slabPools[10]
+		So(len(pool.slabs), ShouldEqual, 10)
+
+		// only the full slabs of 10, 20 and 40 objects remain
+		for _, objAddr := range objAddrs {
+			if pool.slabs[pool.findSlabByAddr(objAddr)].objCount() > 40 {
+				So(o.Delete(objAddr), ShouldBeNil)
+			}
+		}
+		So(len(pool.slabs), ShouldEqual, 3)
+		So(pool.objects, ShouldEqual, 70)
+		So(pool.hasFreeSlot(), ShouldBeFalse)
+
+		Convey("the first added slab should be sized by the live objects", func() {
+			objAddr, err := o.Add([]byte("0123456789"))
+			So(err, ShouldBeNil)
+			So(len(pool.slabs), ShouldEqual, 4)
+			// 10 + 70 live objects * (2 - 1)
+			So(pool.slabs[pool.findSlabByAddr(objAddr)].objCount(), ShouldEqual, 80)
+			So(o.Verify(), ShouldBeNil)
+		})
+	})
+
+	Convey("When a pool grows beyond MaxObjectsPerSlab", t, func() {
+		o, err := New(WithBaseObjectsPerSlab(10), WithGrowthFactor(2), WithMaxObjectsPerSlab(1000))
+		So(err, ShouldBeNil)
+		for i := 0; i < 5000; i++ {
+			_, err := o.Add([]byte(fmt.Sprintf("%10d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("its slabs should be capped", func() {
+			for _, s := range o.slabPools[10].slabs {
+				So(s.objCount(), ShouldBeLessThanOrEqualTo, 1000)
+			}
+		})
+
+		Convey("lowering the limit should affect the existing pools", func() {
+			So(o.SetSlabLimits(100, 0), ShouldBeNil)
+			So(o.slabPools[10].nextObjCount(10, 2), ShouldEqual, 100)
+			So(o.SetSlabLimits(0, 1000), ShouldBeNil)
+			So(slabLenFor(10, o.slabPools[10].nextObjCount(10, 2), 0), ShouldBeLessThanOrEqualTo, 1000)
+		})
+	})
+}
+
 func BenchmarkAddingSearchingObjectInLargePool(b *testing.B) {
 	objSize := uint8(20)
 	objsPerSlab := uint(100)
@@ -315,6 +405,70 @@ func BenchmarkAddingSearchingObjectInLargePool(b *testing.B) {
 	}
 }
 
+func BenchmarkSearchingObjectInLargePoolWithFingerprints(b *testing.B) {
+	sp := NewSlabPool(20)
+	sp.slabFlags = slabFlagFingerprints
+	valueCount := 100000
+	testValues := make([][]byte, valueCount)
+	for i := 0; i < valueCount; i++ {
+		testValues[i] = []byte(fmt.Sprintf("%20d", i+valueCount))
+		if _, _, err := sp.add(testValues[i], 100, 1); err != nil {
+			b.Fatalf("Got error on add: %s", err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, found := sp.search(testValues[rand.Int31n(int32(valueCount))]); !found {
+			b.Fatalf("Value has not been found, but should have")
+		}
+	}
+}
+
+// benchmarkScanThroughput measures how fast a single core scans slabs for
+// an object which doesn't exist. The reported MB/s are bytes of object data
+// scanned per second
+func benchmarkScanThroughput(b *testing.B, objSize uint8, flags uint16) {
+	sp := NewSlabPool(objSize)
+	sp.slabFlags = flags
+	objCount := 1 << 20 / int(objSize)
+	for i := 0; i < objCount; i++ {
+		value := []byte(fmt.Sprintf("%0"+strconv.Itoa(int(objSize))+"d", i))
+		if _, _, err := sp.add(value, 255, 1.3); err != nil {
+			b.Fatalf("Got error on add: %s", err)
+		}
+	}
+	searching := bytes.Repeat([]byte{'x'}, int(objSize))
+	fp := fingerprintOf(searching)
+
+	b.SetBytes(int64(objCount) * int64(objSize))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, sl := range sp.slabs {
+			if _, found := sl.findObj(searching, fp); found {
+				b.Fatalf("Found an object which doesn't exist")
+			}
+		}
+	}
+}
+
+func BenchmarkScanThroughput8(b *testing.B)  { benchmarkScanThroughput(b, 8, 0) }
+func BenchmarkScanThroughput13(b *testing.B) { benchmarkScanThroughput(b, 13, 0) }
+func BenchmarkScanThroughput16(b *testing.B) { benchmarkScanThroughput(b, 16, 0) }
+func BenchmarkScanThroughput32(b *testing.B) { benchmarkScanThroughput(b, 32, 0) }
+func BenchmarkScanThroughput64(b *testing.B) { benchmarkScanThroughput(b, 64, 0) }
+
+func BenchmarkScanThroughputFingerprints8(b *testing.B) {
+	benchmarkScanThroughput(b, 8, slabFlagFingerprints)
+}
+func BenchmarkScanThroughputFingerprints32(b *testing.B) {
+	benchmarkScanThroughput(b, 32, slabFlagFingerprints)
+}
+
 func BenchmarkAddingObjectsGrowthFactor1_3(b *testing.B) {
 	sp := NewSlabPool(10)
 
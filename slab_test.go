@@ -11,7 +11,30 @@ func TestNewSlab(t *testing.T) {
 	newSlab(5, 10)
 }
 
-func TestSlabBitset(t *testing.T) {
+func TestSlabHeader(t *testing.T) {
+	Convey("When creating a new slab", t, func() {
+		slab, err := newSlab(7, 130)
+		So(err, ShouldBeNil)
+
+		Convey("its header should describe the layout", func() {
+			So(slab.valid(), ShouldBeTrue)
+			So(slab.version, ShouldEqual, slabVersion)
+			So(slab.objSize, ShouldEqual, 7)
+			So(slab.objCount(), ShouldEqual, 130)
+			So(len(slab.bitmap()), ShouldEqual, 3)
+			So(slab.getDataOffset(), ShouldEqual, sizeOfSlabHeader+3*8)
+			So(slab.getTotalLength(), ShouldEqual, sizeOfSlabHeader+3*8+7*130)
+			So(slab.empty(), ShouldBeTrue)
+		})
+
+		Convey("and a slab with too many objects should be rejected", func() {
+			_, err := newSlab(1, maxObjsPerSlab+1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSlabBitmap(t *testing.T) {
 	Convey("When creating a new slab", t, func() {
 		objSize := uint8(5)
 		objCount := uint(10000)
@@ -19,23 +42,51 @@ func TestSlabBitset(t *testing.T) {
 		So(err, ShouldBeNil)
 		So(slab.objSize, ShouldEqual, objSize)
 		So(slab.objCount(), ShouldEqual, objCount)
-		Convey("we should be able to obtain and use the bitset from it", func() {
-			bitSet1 := slab.bitSet()
-			bitSet1.Set(2)
-			bitSet1.Set(4)
-			bitSet1.Set(9)
-			Convey("then we can obtain it a second time", func() {
-				bitSet2 := slab.bitSet()
-				So(bitSet2.Test(0), ShouldBeFalse)
-				So(bitSet2.Test(1), ShouldBeFalse)
-				So(bitSet2.Test(2), ShouldBeTrue)
-				So(bitSet2.Test(3), ShouldBeFalse)
-				So(bitSet2.Test(4), ShouldBeTrue)
-				So(bitSet2.Test(5), ShouldBeFalse)
-				So(bitSet2.Test(6), ShouldBeFalse)
-				So(bitSet2.Test(7), ShouldBeFalse)
-				So(bitSet2.Test(8), ShouldBeFalse)
-				So(bitSet2.Test(9), ShouldBeTrue)
+		Convey("we should be able to mark object slots as used", func() {
+			slab.setUsed(2)
+			slab.setUsed(4)
+			slab.setUsed(9)
+			slab.setUsed(64)
+			Convey("then we can read the bits back", func() {
+				So(slab.isUsed(0), ShouldBeFalse)
+				So(slab.isUsed(1), ShouldBeFalse)
+				So(slab.isUsed(2), ShouldBeTrue)
+				So(slab.isUsed(3), ShouldBeFalse)
+				So(slab.isUsed(4), ShouldBeTrue)
+				So(slab.isUsed(5), ShouldBeFalse)
+				So(slab.isUsed(8), ShouldBeFalse)
+				So(slab.isUsed(9), ShouldBeTrue)
+				So(slab.isUsed(63), ShouldBeFalse)
+				So(slab.isUsed(64), ShouldBeTrue)
+
+				slab.setFree(4)
+				So(slab.isUsed(4), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestSlabNextFree(t *testing.T) {
+	Convey("When filling a slab with 65 slots", t, func() {
+		slab, err := newSlab(1, 65)
+		So(err, ShouldBeNil)
+		for i := uint(0); i < 65; i++ {
+			idx, ok := slab.nextFree()
+			So(ok, ShouldBeTrue)
+			So(idx, ShouldEqual, i)
+			_, _, success := slab.addObj([]byte{byte(i)}, idx)
+			So(success, ShouldBeTrue)
+		}
+
+		Convey("there should be no free slot left", func() {
+			_, ok := slab.nextFree()
+			So(ok, ShouldBeFalse)
+			So(slab.full(), ShouldBeTrue)
+			So(slab.live, ShouldEqual, 65)
+
+			Convey("and adding to a used slot should fail", func() {
+				_, _, success := slab.addObj([]byte{1}, 3)
+				So(success, ShouldBeFalse)
 			})
 		})
 	})
@@ -91,3 +142,19 @@ func TestSettingGettingManyObjects(t *testing.T) {
 		})
 	})
 }
+
+func TestReusingObjectSlot(t *testing.T) {
+	Convey("When adding an object to a slab and deleting it again", t, func() {
+		slab, err := newSlab(11, 2)
+		So(err, ShouldBeNil)
+		objAddr, _, success := slab.addObj([]byte("zzzzzzzzzzz"), 0)
+		So(success, ShouldBeTrue)
+		So(slab.delete(objAddr), ShouldBeTrue)
+
+		Convey("the reused slot should only contain the new object", func() {
+			objAddr, _, success = slab.addObj([]byte("aaaaaaaaaaa"), 0)
+			So(success, ShouldBeTrue)
+			So(string(objFromObjAddr(objAddr, 11)), ShouldEqual, "aaaaaaaaaaa")
+		})
+	})
+}
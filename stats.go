@@ -0,0 +1,228 @@
+package gos
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets is the number of buckets in a LatencyHistogram
+const latencyBuckets = 40
+
+// LatencyHistogram is a histogram of operation latencies with exponentially
+// growing buckets. Bucket i counts the operations which took less than 2^i
+// nanoseconds and at least 2^(i-1) nanoseconds, the last bucket also counts
+// all slower operations
+type LatencyHistogram struct {
+	Buckets [latencyBuckets]uint64
+	Count   uint64
+	Total   time.Duration
+}
+
+// Mean returns the average latency of all recorded operations
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound of the latency below which the given
+// fraction (0-1) of the recorded operations completed
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	target := uint64(q * float64(h.Count))
+	var seen uint64
+	for i, count := range h.Buckets {
+		seen += count
+		if seen > target || seen == h.Count {
+			return time.Duration(1) << uint(i)
+		}
+	}
+	return time.Duration(1) << (latencyBuckets - 1)
+}
+
+// Stats is a snapshot of the operation counters of an object store
+// It is only populated if ObjectStoreConfig.CollectStats is enabled
+type Stats struct {
+	Adds         uint64
+	AddsNewSlab  uint64 // number of adds which had to create a new slab
+	Deletes      uint64
+	Gets         uint64
+	SearchHits   uint64
+	SearchMisses uint64
+	Evictions    uint64 // number of objects which have been evicted in the cache mode
+
+	SlabsMapped   uint64
+	SlabsUnmapped uint64
+	BytesMapped   uint64
+	BytesUnmapped uint64
+
+	AddLatency    LatencyHistogram
+	SearchLatency LatencyHistogram
+}
+
+// latencyHistogram is the concurrency safe version of LatencyHistogram
+type latencyHistogram struct {
+	buckets [latencyBuckets]uint64
+	count   uint64
+	total   uint64
+}
+
+// observe records the latency of an operation which started at the given time
+func (h *latencyHistogram) observe(start time.Time) {
+	d := time.Since(start)
+	bucket := bits.Len64(uint64(d))
+	if bucket >= latencyBuckets {
+		bucket = latencyBuckets - 1
+	}
+	atomic.AddUint64(&h.buckets[bucket], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.total, uint64(d))
+}
+
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	var res LatencyHistogram
+	for i := range h.buckets {
+		res.Buckets[i] = atomic.LoadUint64(&h.buckets[i])
+	}
+	res.Count = atomic.LoadUint64(&h.count)
+	res.Total = time.Duration(atomic.LoadUint64(&h.total))
+	return res
+}
+
+func (h *latencyHistogram) reset() {
+	for i := range h.buckets {
+		atomic.StoreUint64(&h.buckets[i], 0)
+	}
+	atomic.StoreUint64(&h.count, 0)
+	atomic.StoreUint64(&h.total, 0)
+}
+
+// opStats holds the operation counters of an object store and its pools
+// All methods can be called on a nil *opStats, in which case they do nothing,
+// this way the instrumentation costs almost nothing when it is disabled
+type opStats struct {
+	adds          uint64
+	addsNewSlab   uint64
+	deletes       uint64
+	gets          uint64
+	searchHits    uint64
+	searchMisses  uint64
+	evictions     uint64
+	slabsMapped   uint64
+	slabsUnmapped uint64
+	bytesMapped   uint64
+	bytesUnmapped uint64
+
+	addLatency    latencyHistogram
+	searchLatency latencyHistogram
+}
+
+func (s *opStats) add(start time.Time, newSlab bool) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.adds, 1)
+	if newSlab {
+		atomic.AddUint64(&s.addsNewSlab, 1)
+	}
+	s.addLatency.observe(start)
+}
+
+func (s *opStats) delete() {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.deletes, 1)
+}
+
+func (s *opStats) get() {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.gets, 1)
+}
+
+func (s *opStats) search(start time.Time, found bool) {
+	if s == nil {
+		return
+	}
+	if found {
+		atomic.AddUint64(&s.searchHits, 1)
+	} else {
+		atomic.AddUint64(&s.searchMisses, 1)
+	}
+	s.searchLatency.observe(start)
+}
+
+func (s *opStats) evict() {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.evictions, 1)
+}
+
+func (s *opStats) slabMapped(bytes uintptr) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.slabsMapped, 1)
+	atomic.AddUint64(&s.bytesMapped, uint64(bytes))
+}
+
+func (s *opStats) slabUnmapped(bytes uintptr) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.slabsUnmapped, 1)
+	atomic.AddUint64(&s.bytesUnmapped, uint64(bytes))
+}
+
+func (s *opStats) snapshot() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	return Stats{
+		Adds:          atomic.LoadUint64(&s.adds),
+		AddsNewSlab:   atomic.LoadUint64(&s.addsNewSlab),
+		Deletes:       atomic.LoadUint64(&s.deletes),
+		Gets:          atomic.LoadUint64(&s.gets),
+		SearchHits:    atomic.LoadUint64(&s.searchHits),
+		SearchMisses:  atomic.LoadUint64(&s.searchMisses),
+		Evictions:     atomic.LoadUint64(&s.evictions),
+		SlabsMapped:   atomic.LoadUint64(&s.slabsMapped),
+		SlabsUnmapped: atomic.LoadUint64(&s.slabsUnmapped),
+		BytesMapped:   atomic.LoadUint64(&s.bytesMapped),
+		BytesUnmapped: atomic.LoadUint64(&s.bytesUnmapped),
+		AddLatency:    s.addLatency.snapshot(),
+		SearchLatency: s.searchLatency.snapshot(),
+	}
+}
+
+func (s *opStats) reset() {
+	if s == nil {
+		return
+	}
+	for _, counter := range []*uint64{
+		&s.adds, &s.addsNewSlab, &s.deletes, &s.gets, &s.searchHits, &s.searchMisses, &s.evictions,
+		&s.slabsMapped, &s.slabsUnmapped, &s.bytesMapped, &s.bytesUnmapped,
+	} {
+		atomic.StoreUint64(counter, 0)
+	}
+	s.addLatency.reset()
+	s.searchLatency.reset()
+}
+
+// Stats returns a snapshot of the operation counters and latency histograms
+// If ObjectStoreConfig.CollectStats is disabled the returned Stats are empty
+func (o *ObjectStore) Stats() Stats {
+	return o.stats.snapshot()
+}
+
+// ResetStats resets all the operation counters and latency histograms to 0
+func (o *ObjectStore) ResetStats() {
+	o.stats.reset()
+}
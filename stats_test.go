@@ -0,0 +1,83 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStats(t *testing.T) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 10
+	c.GrowthFactor = 1
+	c.CollectStats = true
+
+	Convey("When using an object store which collects stats", t, func() {
+		os := NewObjectStore(c)
+		var addrs []ObjAddr
+		for i := 0; i < 25; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		for _, objAddr := range addrs[:5] {
+			_, err := os.Get(objAddr)
+			So(err, ShouldBeNil)
+		}
+		_, found := os.Search([]byte("00003"))
+		So(found, ShouldBeTrue)
+		_, found = os.Search([]byte("abcde"))
+		So(found, ShouldBeFalse)
+		_, found = os.Search([]byte("abc"))
+		So(found, ShouldBeFalse)
+		for _, objAddr := range addrs[20:] {
+			So(os.Delete(objAddr), ShouldBeNil)
+		}
+
+		Convey("the counters should reflect all operations", func() {
+			stats := os.Stats()
+			So(stats.Adds, ShouldEqual, 25)
+			So(stats.AddsNewSlab, ShouldEqual, 3)
+			So(stats.Gets, ShouldEqual, 5)
+			So(stats.SearchHits, ShouldEqual, 1)
+			So(stats.SearchMisses, ShouldEqual, 2)
+			So(stats.Deletes, ShouldEqual, 5)
+			So(stats.SlabsMapped, ShouldEqual, 3)
+			So(stats.SlabsUnmapped, ShouldEqual, 1)
+			So(stats.BytesMapped, ShouldEqual, 3*(16+8+50))
+			So(stats.BytesUnmapped, ShouldEqual, 16+8+50)
+			So(stats.AddLatency.Count, ShouldEqual, 25)
+			So(stats.SearchLatency.Count, ShouldEqual, 3)
+			So(stats.SearchLatency.Quantile(0.5), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("and resetting them should set everything back to 0", func() {
+			os.ResetStats()
+			So(os.Stats(), ShouldResemble, Stats{})
+		})
+	})
+
+	Convey("When using an object store which doesn't collect stats", t, func() {
+		os := NewObjectStore(NewConfig())
+		_, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		So(os.Stats(), ShouldResemble, Stats{})
+	})
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	Convey("When recording latencies into a histogram", t, func() {
+		var h LatencyHistogram
+		h.Buckets[4] = 8  // < 16ns
+		h.Buckets[10] = 2 // < 1024ns
+		h.Count = 10
+		h.Total = 10 * time.Microsecond
+
+		So(h.Mean(), ShouldEqual, time.Microsecond)
+		So(h.Quantile(0.5), ShouldEqual, 16*time.Nanosecond)
+		So(h.Quantile(0.9), ShouldEqual, 1024*time.Nanosecond)
+		So(h.Quantile(1), ShouldEqual, 1024*time.Nanosecond)
+	})
+}
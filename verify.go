@@ -0,0 +1,194 @@
+package gos
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// VerifyError is returned by ObjectStore.Verify, it contains a
+// description of every violated invariant
+type VerifyError struct {
+	Violations []string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("ObjectStore: Verify found %d violations:\n%s", len(e.Violations), strings.Join(e.Violations, "\n"))
+}
+
+// Verify checks all the invariants which the object store relies on. It
+// returns nil if the store is consistent, otherwise it returns a
+// *VerifyError which describes every violation it has found.
+// Verify looks at every slab, so it is slow on large stores
+func (o *ObjectStore) Verify() error {
+	var violations []string
+	report := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	// the lookup table must be sorted in strictly descending order
+	for i := 1; i < len(o.lookupTable); i++ {
+		if o.lookupTable[i-1] <= o.lookupTable[i] {
+			report("lookupTable is not strictly descending at index %d: 0x%x <= 0x%x", i, o.lookupTable[i-1], o.lookupTable[i])
+		}
+	}
+
+	inLookupTable := make(map[SlabAddr]bool, len(o.lookupTable))
+	for _, addr := range o.lookupTable {
+		inLookupTable[addr] = true
+	}
+	inPools := make(map[SlabAddr]bool, len(o.lookupTable))
+
+	for size, pool := range o.slabPools {
+		if pool.objSize != size {
+			report("pool %d: has object size %d", size, pool.objSize)
+		}
+		if len(pool.slabs) == 0 {
+			report("pool %d: has no slabs", size)
+		}
+
+		var objects, capacity, mapped uint64
+		for i, s := range pool.slabs {
+			addr := s.addr()
+			inPools[addr] = true
+			if !inLookupTable[addr] {
+				report("pool %d: slab 0x%x is missing in the lookupTable", size, addr)
+			}
+			if i > 0 && pool.slabs[i-1].addr() <= addr {
+				report("pool %d: slabs are not sorted in descending order at index %d", size, i)
+			}
+
+			if !s.valid() {
+				report("pool %d: slab 0x%x has an invalid header (magic 0x%x, version %d)", size, addr, s.magic, s.version)
+				continue
+			}
+			if s.objSize != size {
+				report("pool %d: slab 0x%x has object size %d", size, addr, s.objSize)
+			}
+			for _, violation := range s.verify() {
+				report("pool %d: slab 0x%x %s", size, addr, violation)
+			}
+			if pool.freeSlabs.Test(uint(i)) != s.full() {
+				report("pool %d: freeSlabs bit %d is %t, but the slab 0x%x full state is %t", size, i, pool.freeSlabs.Test(uint(i)), addr, s.full())
+			}
+
+			objects += uint64(s.live)
+			capacity += uint64(s.objCount())
+			mapped += uint64(s.getTotalLength())
+		}
+
+		if pool.freeSlabs.Len() > uint(len(pool.slabs)) {
+			if next, found := pool.freeSlabs.NextSet(uint(len(pool.slabs))); found {
+				report("pool %d: freeSlabs bit %d is set, but there are only %d slabs", size, next, len(pool.slabs))
+			}
+		}
+		if pool.objects != objects {
+			report("pool %d: object count is %d, but its slabs contain %d objects", size, pool.objects, objects)
+		}
+		if pool.capacity != capacity {
+			report("pool %d: capacity is %d, but its slabs have %d slots", size, pool.capacity, capacity)
+		}
+		if pool.mapped != mapped {
+			report("pool %d: mapped bytes are %d, but its slabs have %d bytes", size, pool.mapped, mapped)
+		}
+		if pool.filter != nil {
+			if pool.filter.count != objects {
+				report("pool %d: filter contains %d objects, but its slabs contain %d objects", size, pool.filter.count, objects)
+			}
+			for _, s := range pool.slabs {
+				if !s.valid() {
+					continue
+				}
+				for idx := uint(0); idx < s.objCount(); idx++ {
+					if s.isUsed(idx) && !pool.filter.contains(objHash(s.getObjByIdx(idx))) {
+						report("pool %d: filter doesn't contain object %d of slab 0x%x", size, idx, s.addr())
+					}
+				}
+			}
+		}
+	}
+
+	for _, addr := range o.lookupTable {
+		if !inPools[addr] {
+			report("lookupTable: slab 0x%x doesn't belong to any pool", addr)
+		}
+	}
+
+	// slabs must not overlap, the lookup table is sorted in descending order
+	// so each slab must end before the previous one starts
+	for i := 1; i < len(o.lookupTable); i++ {
+		addr := o.lookupTable[i]
+		if !inPools[addr] || !slabFromSlabAddr(addr).valid() {
+			continue
+		}
+		if end := addr + slabFromSlabAddr(addr).getTotalLength(); end > o.lookupTable[i-1] {
+			report("lookupTable: slab 0x%x ends at 0x%x which overlaps slab 0x%x", addr, end, o.lookupTable[i-1])
+		}
+	}
+
+	if o.index != nil {
+		for _, violation := range o.index.verify(o) {
+			report("index: %s", violation)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &VerifyError{Violations: violations}
+	}
+	return nil
+}
+
+// verify checks the consistency of the slab's bitmap with its header
+// It returns a description of each violation
+func (s *slab) verify() (violations []string) {
+	bitmap := s.bitmap()
+	objCount := s.objCount()
+
+	var used uint32
+	for _, word := range bitmap {
+		used += uint32(bits.OnesCount64(word))
+	}
+	if used != s.live {
+		violations = append(violations, fmt.Sprintf("has %d used slots in its bitmap, but a live count of %d", used, s.live))
+	}
+
+	// bits past the number of objects must never be set
+	if rem := objCount % 64; rem != 0 && len(bitmap) > 0 {
+		if bitmap[len(bitmap)-1]>>rem != 0 {
+			violations = append(violations, fmt.Sprintf("has bits set past its object count of %d", objCount))
+		}
+	}
+
+	if s.flags&slabFlagLengthPrefix != 0 {
+		for idx := uint(0); idx < objCount; idx++ {
+			if length := len(s.getObjByIdx(idx)); s.isUsed(idx) && (length == 0 || length > int(s.objSize)) {
+				violations = append(violations, fmt.Sprintf("has a length of %d for object %d", length, idx))
+			}
+		}
+	}
+
+	if s.flags&slabFlagFingerprints != 0 {
+		fps := s.fingerprintBytes()
+		for idx := uint(0); idx < objCount; idx++ {
+			// objects with an invalid length have already been reported
+			if obj := s.getObjByIdx(idx); s.isUsed(idx) && len(obj) <= int(s.objSize) && fps[idx] != fingerprintOf(obj) {
+				violations = append(violations, fmt.Sprintf("has a wrong fingerprint for object %d", idx))
+			}
+		}
+	}
+
+	if s.flags&slabFlagExpiry != 0 {
+		expiries := s.expiries()
+		for idx := uint(0); idx < objCount; idx++ {
+			if !s.isUsed(idx) && expiries[idx] != 0 {
+				violations = append(violations, fmt.Sprintf("has an expiry timestamp for the free slot %d", idx))
+			}
+		}
+	}
+
+	if s.empty() {
+		violations = append(violations, "is empty but hasn't been deleted")
+	}
+
+	return violations
+}
@@ -0,0 +1,64 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVerify(t *testing.T) {
+	c := NewConfig()
+	c.BaseObjectsPerSlab = 10
+	c.GrowthFactor = 1.5
+
+	Convey("When adding and deleting objects of various sizes", t, func() {
+		os := NewObjectStore(c)
+		var addrs []ObjAddr
+		for i := 0; i < 500; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%0*d", 1+i%7, i)))
+			So(err, ShouldBeNil)
+			addrs = append(addrs, objAddr)
+		}
+		for i := 0; i < len(addrs); i += 3 {
+			So(os.Delete(addrs[i]), ShouldBeNil)
+		}
+
+		Convey("the store should be consistent", func() {
+			So(os.Verify(), ShouldBeNil)
+		})
+
+		Convey("a lookupTable which is out of order should be detected", func() {
+			os.lookupTable[0], os.lookupTable[1] = os.lookupTable[1], os.lookupTable[0]
+			err := os.Verify()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "lookupTable is not strictly descending")
+		})
+
+		Convey("a slab which is missing in the lookupTable should be detected", func() {
+			os.lookupTable = os.lookupTable[1:]
+			So(os.Verify().Error(), ShouldContainSubstring, "is missing in the lookupTable")
+		})
+
+		Convey("a wrong freeSlabs bit should be detected", func() {
+			pool := os.slabPools[3]
+			pool.freeSlabs.Flip(0)
+			So(os.Verify().Error(), ShouldContainSubstring, "freeSlabs bit 0")
+		})
+
+		Convey("a corrupted slab should be detected", func() {
+			s := os.slabPools[4].slabs[0]
+			s.setUsed(s.objCount())
+			os.slabPools[4].objects++
+			err := os.Verify().(*VerifyError)
+			So(err.Error(), ShouldContainSubstring, "used slots in its bitmap")
+			So(err.Error(), ShouldContainSubstring, "bits set past its object count")
+			So(err.Error(), ShouldContainSubstring, "object count is")
+		})
+
+		Convey("a slab with the wrong object size should be detected", func() {
+			os.slabPools[5].slabs[0].objSize = 6
+			So(os.Verify().Error(), ShouldContainSubstring, "has object size 6")
+		})
+	})
+}